@@ -0,0 +1,65 @@
+package singleflightx
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is reported for the keys absent from the results of the
+// function given to DoX, DoXContext, DoXResults or DoChanX, with the
+// AbsentError policy.
+var ErrNotFound = errors.New("singleflightx: not found")
+
+// AbsentPolicy defines how the keys absent from the results of the function
+// given to DoX, DoXContext, DoXResults or DoChanX are reported. A key is
+// absent if the function returned neither a value nor an error for it.
+type AbsentPolicy int
+
+const (
+	// AbsentNull reports absent keys with a `Valid` field set to false.
+	AbsentNull AbsentPolicy = iota
+	// AbsentError reports absent keys with ErrNotFound.
+	AbsentError
+	// AbsentDefault reports absent keys with a default value.
+	AbsentDefault
+)
+
+func validateAbsentPolicy(policy AbsentPolicy) error {
+	if policy != AbsentNull && policy != AbsentError && policy != AbsentDefault {
+		return fmt.Errorf("singleflightx: invalid absent policy %d", policy)
+	}
+	return nil
+}
+
+// absentResult applies policy to r, if absent. def is the default value of
+// the AbsentDefault policy.
+func absentResult[V any](r Result[V], policy AbsentPolicy, def V) Result[V] {
+	if r.Value.Valid || r.Err != nil {
+		return r
+	}
+
+	switch policy {
+	case AbsentError:
+		r.Err = ErrNotFound
+	case AbsentDefault:
+		r.Value = NullValue[V]{def, true}
+	}
+
+	return r
+}
+
+// absentResults applies the absent policy of the call, or else the one of
+// g, to results.
+func (g *Group[K, V]) absentResults(results map[K]Result[V], co callOptions[K, V]) {
+	policy, def := g.AbsentPolicy, g.AbsentDefault
+	if co.absent {
+		policy, def = co.absentPolicy, co.absentDefault
+	}
+	if policy == AbsentNull {
+		return
+	}
+
+	for k, r := range results {
+		results[k] = absentResult(r, policy, def)
+	}
+}
@@ -0,0 +1,202 @@
+package singleflightx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// NewBatcher returns a Batcher executing the keys of its callers through
+// fn, configured with opts. WithBatchWindow is required. Other options
+// apply to the underlying group. An error is returned if the configuration
+// is invalid.
+func NewBatcher[K comparable, V any](fn func(context.Context, []K) (map[K]V, error), opts ...Option) (*Batcher[K, V], error) {
+	if fn == nil {
+		return nil, errors.New("singleflightx: nil batch function")
+	}
+
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.shards != 0 || cfg.hasher != nil {
+		return nil, errors.New("singleflightx: WithShards and WithHasher are only supported by NewShardedGroup")
+	}
+	if cfg.maxWait == 0 {
+		return nil, errors.New("singleflightx: WithBatchWindow is required")
+	}
+
+	b := &Batcher[K, V]{fn: fn, maxWait: cfg.maxWait, maxSize: cfg.maxSize}
+	if err := configure(&b.group, cfg); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Batcher collects the calls of single keys over a short window, and
+// executes the distinct keys through a single call of its function. Like
+// Group, only one execution is in-flight for a given key at a time: the
+// callers of a key already pending or executing wait for its result.
+//
+// A batch is executed once its first key has waited for the maximum wait
+// of the window, or as soon as it holds the maximum number of keys,
+// whichever happens first.
+type Batcher[K comparable, V any] struct {
+	group   Group[K, V]
+	fn      func(context.Context, []K) (map[K]V, error)
+	maxWait time.Duration
+	maxSize int
+	window  window[K, V]
+}
+
+// Do returns the result of key, once executed as part of a batch.
+// Result.Value is not valid if the function of the batcher did not return
+// key, unless configured otherwise with WithAbsentPolicy or
+// WithAbsentDefault.
+func (b *Batcher[K, V]) Do(key K) Result[V] {
+	return b.DoContext(context.Background(), key)
+}
+
+// DoContext is like Do but gives up waiting as soon as ctx is done, in
+// which case ctx.Err() is reported. The pending or in-flight call is not
+// interrupted and keeps running for the other callers of the same key.
+//
+// The context passed to the function of the batcher carries the values of
+// the ctx of the first caller of the batch, unless the batcher is detached,
+// and the latest deadline among the callers of the keys of the batch. It is
+// canceled once every caller of these keys has given up waiting.
+func (b *Batcher[K, V]) DoContext(ctx context.Context, key K) Result[V] {
+	calls, toCall := b.group.registerX(ctx, []K{key}, nil, nil, b.group.newCandidate(ctx, b.fn))
+	b.window.add(&b.group, ctx, calls, toCall, b.fn, 0, b.maxWait, b.maxSize)
+	return absentResult(b.group.waitContextX(ctx, calls)[key], b.group.AbsentPolicy, b.group.AbsentDefault)
+}
+
+// DoChan is like Do but returns a channel that will receive the
+// result when it is ready.
+//
+// The returned channel will not be closed.
+func (b *Batcher[K, V]) DoChan(key K) <-chan Result[V] {
+	ch := make(chan Result[V], 1)
+	calls, toCall := b.group.registerX(context.Background(), []K{key}, map[K]chan Result[V]{key: ch}, nil, b.group.newCandidate(context.Background(), b.fn))
+	b.window.add(&b.group, context.Background(), calls, toCall, b.fn, 0, b.maxWait, b.maxSize)
+	return ch
+}
+
+// Forget tells the batcher to forget about a key. Future calls to Do for
+// this key will add it to a new batch rather than waiting for an earlier
+// call to complete.
+func (b *Batcher[K, V]) Forget(key K) {
+	b.group.Forget(key)
+}
+
+// window collects the calls of keys over a short time window, to execute
+// them through a single function. The calls given with different func
+// values are collected in distinct batches. The zero value is ready to use.
+type window[K comparable, V any] struct {
+	mu      sync.Mutex               // protects batches
+	batches map[uintptr]*batch[K, V] // lazily initialized, by function
+}
+
+// batch is a pending batch of a window.
+type batch[K comparable, V any] struct {
+	ctx   context.Context
+	fn    func(context.Context, []K) (map[K]V, error)
+	calls map[K]*call[K, V]
+	keys  []K
+	timer *time.Timer
+}
+
+// funcID identifies the func value fn, so that only the calls of the same
+// func value are merged. Unlike the code pointer of fn, it tells apart the
+// closures of the same function literal, which may capture different
+// variables, and the method values of different receivers. fn must be a
+// func. The identity cannot be reused while a batch of fn is pending, as
+// the batch keeps fn alive.
+func funcID[F any](fn F) uintptr {
+	return uintptr(*(*unsafe.Pointer)(unsafe.Pointer(&fn)))
+}
+
+// add appends the calls of keys to the pending batch of fn in w, on behalf
+// of a caller waiting with ctx, and executes it if it holds maxSize keys,
+// unless maxSize is zero. Otherwise, the batch is executed once maxWait is
+// elapsed. id identifies fn, see funcID. ctx and fn are the ones of the
+// batch if it is a new one. Otherwise, the caller joins the batch.
+func (w *window[K, V]) add(g *Group[K, V], ctx context.Context, calls map[K]*call[K, V], keys []K, fn func(context.Context, []K) (map[K]V, error), id uintptr, maxWait time.Duration, maxSize int) {
+	if len(keys) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	if w.batches == nil {
+		w.batches = make(map[uintptr]*batch[K, V])
+	}
+	b, joined := w.batches[id]
+	if !joined {
+		b = &batch[K, V]{ctx: ctx, fn: fn, calls: make(map[K]*call[K, V])}
+		w.batches[id] = b
+	}
+	for _, k := range keys {
+		b.calls[k] = calls[k]
+		b.keys = append(b.keys, k)
+	}
+
+	full := maxSize > 0 && len(b.keys) >= maxSize
+	if full {
+		w.take(id, b)
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(maxWait, func() {
+			w.flush(g, id, b)
+		})
+	}
+	w.mu.Unlock()
+
+	if joined {
+		g.joinBatch(ctx, calls, keys)
+	}
+	if full {
+		go g.doCallXContext(b.ctx, b.calls, b.keys, b.fn)
+	}
+}
+
+// joinBatch notifies the observer and the tracer that a caller waiting with
+// ctx joined, with the calls of keys, a batch started by another caller.
+func (g *Group[K, V]) joinBatch(ctx context.Context, calls map[K]*call[K, V], keys []K) {
+	traceCtxs := make([]context.Context, len(keys))
+
+	g.mu.Lock()
+	for i, k := range keys {
+		traceCtxs[i] = g.addTraceJoin(ctx, calls[k])
+	}
+	g.mu.Unlock()
+
+	g.observeJoin(keys...)
+	for i, k := range keys {
+		g.traceJoin(ctx, traceCtxs[i], k)
+	}
+}
+
+// flush executes the batch b of w, unless already executed.
+func (w *window[K, V]) flush(g *Group[K, V], id uintptr, b *batch[K, V]) {
+	w.mu.Lock()
+	taken := w.take(id, b)
+	w.mu.Unlock()
+
+	if taken {
+		g.doCallXContext(b.ctx, b.calls, b.keys, b.fn)
+	}
+}
+
+// take removes the batch b from w, and reports whether it was still
+// pending. It must be called with w.mu held.
+func (w *window[K, V]) take(id uintptr, b *batch[K, V]) bool {
+	if w.batches[id] != b {
+		return false
+	}
+	delete(w.batches, id)
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	return true
+}
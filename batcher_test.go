@@ -0,0 +1,174 @@
+package singleflightx
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedBatches struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (r *recordedBatches) fn(ctx context.Context, keys []int) (map[int]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	batch := append([]int{}, keys...)
+	sort.Ints(batch)
+	r.batches = append(r.batches, batch)
+
+	results := map[int]int{}
+	for _, k := range keys {
+		if k >= 0 {
+			results[k] = k * 2
+		}
+	}
+	return results, nil
+}
+
+func (r *recordedBatches) get() [][]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.batches
+}
+
+func TestNewBatcher(t *testing.T) {
+	is := assert.New(t)
+
+	fn := (&recordedBatches{}).fn
+	b, err := NewBatcher[int, int](fn, WithBatchWindow(time.Millisecond, 10), WithPanicPolicy(PanicAsError))
+	is.NoError(err)
+	is.Equal(time.Millisecond, b.maxWait)
+	is.Equal(10, b.maxSize)
+	is.Equal(PanicAsError, b.group.PanicPolicy)
+
+	_, err = NewBatcher[int, int](nil, WithBatchWindow(time.Millisecond, 10))
+	is.EqualError(err, "singleflightx: nil batch function")
+	_, err = NewBatcher[int, int](fn)
+	is.EqualError(err, "singleflightx: WithBatchWindow is required")
+	_, err = NewBatcher[int, int](fn, WithBatchWindow(0, 10))
+	is.EqualError(err, "singleflightx: the maximum wait of the batch window must be greater than zero")
+	_, err = NewBatcher[int, int](fn, WithBatchWindow(time.Millisecond, -1))
+	is.EqualError(err, "singleflightx: the maximum size of the batch window must not be negative")
+	_, err = NewBatcher[int, int](fn, WithBatchWindow(time.Millisecond, 10), WithShards(2))
+	is.EqualError(err, "singleflightx: WithShards and WithHasher are only supported by NewShardedGroup")
+	_, err = NewGroup[int, int](WithBatchWindow(time.Millisecond, 10))
+	is.EqualError(err, "singleflightx: WithBatchWindow is only supported by NewBatcher")
+}
+
+func TestBatcherDo(t *testing.T) {
+	is := assert.New(t)
+
+	r := &recordedBatches{}
+	b, err := NewBatcher[int, int](r.fn, WithBatchWindow(50*time.Millisecond, 0))
+	is.NoError(err)
+
+	keys := []int{1, 2, 3, 2, -1}
+	results := make([]Result[int], len(keys))
+
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Add(1)
+		go func(i int, k int) {
+			defer wg.Done()
+			results[i] = b.Do(k)
+		}(i, k)
+	}
+	wg.Wait()
+
+	is.Equal([][]int{{-1, 1, 2, 3}}, r.get())
+	for i, k := range keys[:4] {
+		is.NoError(results[i].Err)
+		is.True(results[i].Value.Valid)
+		is.Equal(k*2, results[i].Value.Value)
+	}
+	is.True(results[1].Shared)
+	is.True(results[3].Shared)
+	is.False(results[0].Shared)
+
+	// absent key
+	is.NoError(results[4].Err)
+	is.False(results[4].Value.Valid)
+	is.Len(b.group.m, 0)
+}
+
+func TestBatcherMaxSize(t *testing.T) {
+	is := assert.New(t)
+
+	r := &recordedBatches{}
+	b, err := NewBatcher[int, int](r.fn, WithBatchWindow(time.Hour, 2))
+	is.NoError(err)
+
+	ch1 := b.DoChan(1)
+	ch2 := b.DoChan(2)
+	is.Equal(2, (<-ch1).Value.Value)
+	is.Equal(4, (<-ch2).Value.Value)
+	is.Equal([][]int{{1, 2}}, r.get())
+}
+
+func TestBatcherDoContext(t *testing.T) {
+	is := assert.New(t)
+
+	someErr := errors.New("error")
+	started := make(chan struct{})
+	b, err := NewBatcher[int, int](func(ctx context.Context, keys []int) (map[int]int, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, someErr
+	}, WithBatchWindow(time.Millisecond, 0))
+	is.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan Result[int])
+	go func() {
+		done <- b.DoContext(ctx, 1)
+	}()
+	<-started
+
+	cancel()
+	res := <-done
+	is.Equal(context.Canceled, res.Err)
+	is.False(res.Value.Valid)
+}
+
+func TestBatcherTracer(t *testing.T) {
+	is := assert.New(t)
+
+	type callerKey struct{}
+	tracer := &recordingTracer[int]{}
+	b, err := NewBatcher[int, int](func(ctx context.Context, keys []int) (map[int]int, error) {
+		is.Equal("first", ctx.Value(callerKey{}))
+		return map[int]int{1: 1, 2: 2}, nil
+	}, WithBatchWindow(time.Hour, 2), WithTracer[int](tracer))
+	is.NoError(err)
+
+	done := make(chan Result[int])
+	go func() {
+		done <- b.DoContext(context.WithValue(context.Background(), callerKey{}, "first"), 1)
+	}()
+	is.Eventually(func() bool {
+		b.window.mu.Lock()
+		defer b.window.mu.Unlock()
+		return len(b.window.batches) == 1
+	}, time.Second, time.Millisecond)
+
+	secondCtx := context.WithValue(context.Background(), callerKey{}, "second")
+	is.Equal(2, b.DoContext(secondCtx, 2).Value.Value)
+	is.Equal(1, (<-done).Value.Value)
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	is.Len(tracer.starts, 1)
+	is.Equal("first", tracer.starts[0].Value(callerKey{}))
+	is.Len(tracer.joins, 1)
+	is.Equal(secondCtx, tracer.joins[0].ctx)
+	is.Equal(2, tracer.joins[0].key)
+	is.Equal([]int{1, 2}, tracer.joins[0].execCtx.Value(tracerCtxKey{}))
+}
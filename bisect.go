@@ -0,0 +1,98 @@
+package singleflightx
+
+import "context"
+
+// FailurePolicy defines how the keys of a batch are handled when the
+// function given to DoX, DoXContext, DoXResults or DoChanX returns an error
+// or panics.
+type FailurePolicy int
+
+const (
+	// FailBatch reports the error, or the panic, to every key of the batch.
+	FailBatch FailurePolicy = iota
+	// FailBisect splits the keys of a failed batch in halves and retries
+	// each half, until the keys that fail on their own are found. Only these
+	// keys are reported with their error, while the other keys get their
+	// result. A key that panics on its own is reported with a *PanicError,
+	// handled according to the PanicPolicy by the callers of the key, and
+	// received as an error on their channels. Bisection stops as soon as
+	// every caller of the batch has given up waiting. When every key fails,
+	// a batch of N keys costs 2N-1 executions: bound N with MaxBatchSize.
+	FailBisect
+)
+
+// bisect returns fn, bisecting the keys of failed executions if g has the
+// FailBisect policy.
+func (g *Group[K, V]) bisect(fn func(context.Context, []K) (map[K]V, error)) func(context.Context, []K) (map[K]V, error) {
+	if g.FailurePolicy != FailBisect {
+		return fn
+	}
+
+	return func(ctx context.Context, keys []K) (map[K]V, error) {
+		values := make(map[K]V, len(keys))
+		errs := map[K]error{}
+		bisectX(ctx, fn, keys, values, errs)
+
+		for _, err := range errs {
+			if err != nil {
+				return values, keyErrors[K]{errs: errs}
+			}
+		}
+		return values, nil
+	}
+}
+
+// bisectX executes fn for keys, and bisects them on failure. The results
+// are collected into values and errs.
+func bisectX[K comparable, V any](ctx context.Context, fn func(context.Context, []K) (map[K]V, error), keys []K, values map[K]V, errs map[K]error) {
+	v, err := tryX(ctx, fn, keys)
+
+	if keyErrs, ok := err.(keyErrors[K]); ok {
+		// Keys that have their own result do not need to be bisected, only
+		// the ones failed by the error of the whole execution.
+		failed := []K{}
+		for _, k := range keys {
+			if e, ok := keyErrs.errs[k]; ok {
+				errs[k] = e
+			} else if value, ok := v[k]; ok {
+				values[k] = value
+			} else {
+				failed = append(failed, k)
+			}
+		}
+		if keyErrs.err == nil || len(failed) == 0 {
+			return
+		}
+		keys, err = failed, keyErrs.err
+	} else if err == nil {
+		for _, k := range keys {
+			if value, ok := v[k]; ok {
+				values[k] = value
+			}
+		}
+		return
+	}
+
+	if len(keys) == 1 || ctx.Err() != nil {
+		for _, k := range keys {
+			errs[k] = err
+		}
+		return
+	}
+
+	mid := len(keys) / 2
+	bisectX(ctx, fn, keys[:mid], values, errs)
+	bisectX(ctx, fn, keys[mid:], values, errs)
+}
+
+// tryX executes fn for keys, and reports a panic as a *PanicError. A
+// runtime.Goexit is not stopped.
+func tryX[K comparable, V any](ctx context.Context, fn func(context.Context, []K) (map[K]V, error), keys []K) (values map[K]V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			values, err = nil, newPanicError(r)
+		}
+	}()
+
+	return fn(ctx, keys)
+}
@@ -0,0 +1,258 @@
+package singleflightx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// callContext is the context passed to the function of an execution. It
+// carries the values of the context of the caller that started the
+// execution, and the latest deadline among the callers still waiting. It is
+// canceled once every caller has given up waiting.
+type callContext struct {
+	parent context.Context // only used for values
+	done   chan struct{}
+
+	mu       sync.Mutex // protects following fields
+	err      error
+	deadline time.Time // zero if no deadline
+	timer    *time.Timer
+}
+
+func newCallContext(parent context.Context) *callContext {
+	return &callContext{parent: parent, done: make(chan struct{})}
+}
+
+func (c *callContext) Deadline() (deadline time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline, !c.deadline.IsZero()
+}
+
+func (c *callContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *callContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *callContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// cancel closes c.done and records err, unless c is already canceled.
+func (c *callContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return
+	}
+
+	c.err = err
+	close(c.done)
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+}
+
+// setDeadline moves the deadline of c, either earlier or later. A zero
+// deadline removes it.
+func (c *callContext) setDeadline(deadline time.Time) {
+	c.mu.Lock()
+	if c.err != nil || c.deadline.Equal(deadline) {
+		c.mu.Unlock()
+		return
+	}
+
+	c.deadline = deadline
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+
+	if deadline.IsZero() {
+		c.mu.Unlock()
+		return
+	}
+
+	d := time.Until(deadline)
+	if d <= 0 {
+		c.mu.Unlock()
+		c.cancel(context.DeadlineExceeded)
+		return
+	}
+
+	c.timer = time.AfterFunc(d, func() {
+		c.cancel(context.DeadlineExceeded)
+	})
+	c.mu.Unlock()
+}
+
+// execution is a single invocation of a user given function, on behalf of
+// the calls of one or many keys. Its context is canceled once every caller
+// of these keys has given up waiting.
+type execution[K comparable, V any] struct {
+	keys  []K
+	calls []*call[K, V]
+	ctx   *callContext
+}
+
+// startExecution returns the context to be passed to the function in
+// charge of the calls of keys, carrying the values of ctx. The execution is
+// canceled right away if every caller has already given up waiting.
+func (g *Group[K, V]) startExecution(ctx context.Context, calls map[K]*call[K, V], keys []K) (context.Context, *execution[K, V]) {
+	cs := make([]*call[K, V], len(keys))
+	for i, k := range keys {
+		cs[i] = calls[k]
+	}
+
+	e := &execution[K, V]{keys: keys, calls: cs, ctx: newCallContext(ctx)}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, c := range cs {
+		c.exec = e
+	}
+	if e.abandoned() {
+		g.abandon(e)
+	} else {
+		e.updateDeadline()
+	}
+
+	return e.ctx, e
+}
+
+// done releases the resources of the execution once its function returned.
+func (e *execution[K, V]) done() {
+	e.ctx.cancel(context.Canceled)
+}
+
+// abandoned reports whether nobody waits for the execution anymore.
+// It must be called with the singleflight mutex held.
+func (e *execution[K, V]) abandoned() bool {
+	for _, c := range e.calls {
+		if c.waiters > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// updateDeadline sets the deadline of the execution to the latest deadline
+// of the callers still waiting. There is no deadline as soon as one of them
+// has none. It must be called with the singleflight mutex held.
+func (e *execution[K, V]) updateDeadline() {
+	var deadline time.Time
+	for _, c := range e.calls {
+		if c.unbounded > 0 {
+			e.ctx.setDeadline(time.Time{})
+			return
+		}
+		for _, d := range c.deadlines {
+			if d.After(deadline) {
+				deadline = d
+			}
+		}
+	}
+
+	if !deadline.IsZero() {
+		e.ctx.setDeadline(deadline)
+	}
+}
+
+// join registers a caller interested in the result of c. It must be called
+// with the singleflight mutex held.
+func (c *call[K, V]) join(ctx context.Context) {
+	c.waiters++
+	if d, ok := ctx.Deadline(); ok {
+		c.deadlines = append(c.deadlines, d)
+	} else {
+		c.unbounded++
+	}
+
+	if c.exec != nil {
+		c.exec.updateDeadline()
+	}
+}
+
+// unjoin unregisters a caller previously registered with join. It must be
+// called with the singleflight mutex held.
+func (c *call[K, V]) unjoin(ctx context.Context) {
+	c.waiters--
+	if d, ok := ctx.Deadline(); ok {
+		for i := range c.deadlines {
+			if c.deadlines[i].Equal(d) {
+				c.deadlines = append(c.deadlines[:i], c.deadlines[i+1:]...)
+				break
+			}
+		}
+	} else {
+		c.unbounded--
+	}
+}
+
+// leave is called when a caller gives up waiting for calls. Executions
+// that nobody waits for anymore are canceled, and their keys are forgotten
+// so that future calls do not join a canceled execution.
+func (g *Group[K, V]) leave(ctx context.Context, calls ...*call[K, V]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, c := range calls {
+		c.unjoin(ctx)
+	}
+
+	for _, c := range calls {
+		e := c.exec
+		if e == nil {
+			continue
+		}
+
+		if !e.abandoned() {
+			e.updateDeadline()
+			continue
+		}
+
+		g.abandon(e)
+	}
+}
+
+// abandon cancels an execution that nobody waits for anymore, and forgets
+// its keys. It must be called with the singleflight mutex held.
+func (g *Group[K, V]) abandon(e *execution[K, V]) {
+	for i, k := range e.keys {
+		if g.m[k] == e.calls[i] {
+			delete(g.m, k)
+		}
+	}
+	e.ctx.cancel(context.Canceled)
+}
+
+// waitDone blocks until done is closed or ctx is done, and reports whether
+// done was closed. A closed done always wins over a done ctx.
+func waitDone(ctx context.Context, done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+}
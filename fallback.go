@@ -0,0 +1,88 @@
+package singleflightx
+
+import "context"
+
+// errorResults returns the results of keys that all failed with err.
+func errorResults[K comparable, V any](keys []K, err error) map[K]Result[V] {
+	results := make(map[K]Result[V], len(keys))
+	for _, k := range keys {
+		results[k] = Result[V]{Err: err}
+	}
+	return results
+}
+
+// absentKeys returns the keys of results that have neither a value nor an
+// error.
+func absentKeys[K comparable, V any](results map[K]Result[V]) []K {
+	keys := []K{}
+	for k, r := range results {
+		if !r.Value.Valid && r.Err == nil {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// mergeFallbackResults replaces the absent results with the ones of the
+// fallback.
+func mergeFallbackResults[K comparable, V any](results map[K]Result[V], fallbackResults map[K]Result[V]) {
+	for k, r := range fallbackResults {
+		r.Shared = r.Shared || results[k].Shared
+		results[k] = r
+	}
+}
+
+// startFallbackX resolves keys one by one through fallback, on behalf of a
+// caller waiting with ctx, and returns their calls.
+func (g *Group[K, V]) startFallbackX(ctx context.Context, keys []K, fallback func(K) (V, error)) map[K]*call[K, V] {
+	fn := func(_ context.Context, keys []K) (map[K]V, error) {
+		v, err := fallback(keys[0])
+		return map[K]V{keys[0]: v}, err
+	}
+
+	calls, toCall := g.registerX(ctx, keys, nil, nil, g.newCandidate(ctx, fn))
+	for _, k := range toCall {
+		go g.doCallXContext(ctx, calls, []K{k}, fn)
+	}
+
+	return calls
+}
+
+// completeX applies the call options to the results of a caller waiting
+// with ctx.
+func (g *Group[K, V]) completeX(ctx context.Context, results map[K]Result[V], co callOptions[K, V]) map[K]Result[V] {
+	if co.fallback != nil {
+		if absent := absentKeys(results); len(absent) > 0 {
+			mergeFallbackResults(results, g.waitContextX(ctx, g.startFallbackX(ctx, absent, co.fallback)))
+		}
+	}
+
+	g.absentResults(results, co)
+
+	return results
+}
+
+// completeX applies the call options to the results of a caller waiting
+// with ctx.
+func (sg *ShardedGroup[K, V]) completeX(ctx context.Context, results map[K]Result[V], co callOptions[K, V]) map[K]Result[V] {
+	if co.fallback != nil {
+		if absent := absentKeys(results); len(absent) > 0 {
+			keysByShard := partitionBy(absent, func(key K) uint {
+				return sg.hasher.computeHash(key, sg.count)
+			})
+
+			calls := make(map[uint]map[K]*call[K, V], len(keysByShard))
+			for i, keys := range keysByShard {
+				calls[i] = sg.shards[i].startFallbackX(ctx, keys, co.fallback)
+			}
+			for i := range calls {
+				mergeFallbackResults(results, sg.shards[i].waitContextX(ctx, calls[i]))
+			}
+		}
+	}
+
+	// Every shard shares the same configuration.
+	sg.shards[0].absentResults(results, co)
+
+	return results
+}
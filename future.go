@@ -0,0 +1,105 @@
+package singleflightx
+
+import "context"
+
+// Future is the result of a call started by Go or GoX, that will be ready
+// later. Unlike the channels of DoChan, a Future can be awaited and peeked
+// at by many consumers, any number of times.
+type Future[V any] struct {
+	done   <-chan struct{}
+	result func() Result[V]
+}
+
+// Done returns a channel that is closed once the result is ready.
+func (f *Future[V]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Await waits for the result, or for ctx to be done, in which case
+// ctx.Err() is reported. Giving up waiting does not interrupt the call. A
+// panic of the given function is propagated to the caller, like Do, unless
+// the panic policy of the group is PanicAsError. A runtime.Goexit of the
+// given function is reported as ErrGoexit.
+func (f *Future[V]) Await(ctx context.Context) Result[V] {
+	if !waitDone(ctx, f.done) {
+		return Result[V]{Err: ctx.Err()}
+	}
+
+	return f.result()
+}
+
+// Peek returns the result without blocking, and reports whether it is
+// ready.
+func (f *Future[V]) Peek() (Result[V], bool) {
+	select {
+	case <-f.done:
+		return f.result(), true
+	default:
+		return Result[V]{}, false
+	}
+}
+
+// Go is like Do but returns immediately a Future of the result, while the
+// given function runs on a goroutine owned by the group. The Future waits
+// for the call until it completes, so the call is never abandoned.
+func (g *Group[K, V]) Go(key K, fn func() (V, error)) *Future[V] {
+	return g.future(g.startCall(key, fn, nil), AbsentNull)
+}
+
+// GoX is like DoX but returns immediately a Future of the result of each
+// key, while the given function runs on a goroutine owned by the group.
+// See Go.
+func (g *Group[K, V]) GoX(keys []K, fn func([]K) (map[K]V, error)) map[K]*Future[V] {
+	ctxFn := ignoreContext(fn)
+	calls, toCall := g.registerX(context.Background(), keys, nil, nil, g.newCandidate(context.Background(), ctxFn))
+
+	g.startCallX(context.Background(), calls, toCall, ctxFn, funcID(fn))
+
+	futures := make(map[K]*Future[V], len(calls))
+	for k, c := range calls {
+		futures[k] = g.future(c, g.AbsentPolicy)
+	}
+
+	return futures
+}
+
+// future returns the Future of c. policy is the absent policy of its
+// result.
+func (g *Group[K, V]) future(c *call[K, V], policy AbsentPolicy) *Future[V] {
+	return &Future[V]{
+		done: c.done,
+		result: func() Result[V] {
+			if c.err == ErrGoexit {
+				// The function did not run on the goroutine of the consumer,
+				// which must not be terminated.
+				return Result[V]{Err: c.err, Shared: c.dups > 0}
+			}
+			return absentResult(g.resultX(c), policy, g.AbsentDefault)
+		},
+	}
+}
+
+// Go is like Do but returns immediately a Future of the result. See
+// Group.Go.
+func (sg *ShardedGroup[K, V]) Go(key K, fn func() (V, error)) *Future[V] {
+	i := sg.hasher.computeHash(key, sg.count)
+	return sg.shards[i].Go(key, fn)
+}
+
+// GoX is like DoX but returns immediately a Future of the result of each
+// key. See Group.GoX. If the keys match different shards, fn is called once
+// per shard, concurrently.
+func (sg *ShardedGroup[K, V]) GoX(keys []K, fn func([]K) (map[K]V, error)) map[K]*Future[V] {
+	keysByShard := partitionBy(keys, func(key K) uint {
+		return sg.hasher.computeHash(key, sg.count)
+	})
+
+	futures := make(map[K]*Future[V], len(keys))
+	for i, keys := range keysByShard {
+		for k, f := range sg.shards[i].GoX(keys, fn) {
+			futures[k] = f
+		}
+	}
+
+	return futures
+}
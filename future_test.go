@@ -0,0 +1,144 @@
+package singleflightx
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGo(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+	var calls int32
+
+	release := make(chan struct{})
+	fn := func() (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+
+	f1 := g.Go("key", fn)
+	f2 := g.Go("key", fn)
+
+	_, ok := f1.Peek()
+	is.False(ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r := f1.Await(ctx)
+	is.ErrorIs(r.Err, context.DeadlineExceeded)
+
+	close(release)
+	<-f1.Done()
+
+	for _, f := range []*Future[int]{f1, f1, f2} {
+		r = f.Await(context.Background())
+		is.NoError(r.Err)
+		is.Equal(42, r.Value.Value)
+		is.True(r.Shared)
+	}
+
+	r, ok = f2.Peek()
+	is.True(ok)
+	is.Equal(42, r.Value.Value)
+	is.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGoPanic(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[string, int]{PanicPolicy: PanicAsError}
+
+	f := g.Go("key", func() (int, error) {
+		panic("boom")
+	})
+
+	var panicErr *PanicError
+	is.ErrorAs(f.Await(context.Background()).Err, &panicErr)
+
+	g2 := Group[string, int]{PanicPolicy: PanicRepanic}
+	f = g2.Go("key", func() (int, error) {
+		panic("boom")
+	})
+	<-f.Done()
+
+	is.Panics(func() {
+		f.Peek()
+	})
+}
+
+func TestGoGoexit(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+
+	f := g.Go("key", func() (int, error) {
+		runtime.Goexit()
+		return 0, nil
+	})
+	<-f.Done()
+
+	r, ok := f.Peek()
+	is.True(ok)
+	is.ErrorIs(r.Err, ErrGoexit)
+	is.ErrorIs(f.Await(context.Background()).Err, ErrGoexit)
+
+	futures := g.GoX([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+		runtime.Goexit()
+		return nil, nil
+	})
+	for _, f := range futures {
+		is.ErrorIs(f.Await(context.Background()).Err, ErrGoexit)
+	}
+}
+
+func TestGoX(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[string, int]{AbsentPolicy: AbsentError}
+
+	release := make(chan struct{})
+	futures := g.GoX([]string{"a", "b", "a"}, func(keys []string) (map[string]int, error) {
+		<-release
+		return map[string]int{"a": 1}, nil
+	})
+	is.Len(futures, 2)
+
+	f := g.Go("a", func() (int, error) {
+		return 0, errors.New("unexpected")
+	})
+
+	close(release)
+	is.Equal(1, futures["a"].Await(context.Background()).Value.Value)
+	is.ErrorIs(futures["b"].Await(context.Background()).Err, ErrNotFound)
+
+	r := f.Await(context.Background())
+	is.Equal(1, r.Value.Value)
+	is.True(r.Shared)
+}
+
+func TestShardedGroupGoX(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t)
+
+	futures := sg.GoX([]int{1, 2, 3}, func(keys []int) (map[int]int, error) {
+		is.Len(keys, 1)
+		return map[int]int{keys[0]: keys[0] * 2}, nil
+	})
+	is.Len(futures, 3)
+	for k, f := range futures {
+		is.Equal(k*2, f.Await(context.Background()).Value.Value)
+	}
+
+	f := sg.Go(4, func() (int, error) {
+		return 8, nil
+	})
+	is.Equal(8, f.Await(context.Background()).Value.Value)
+}
@@ -0,0 +1,93 @@
+package singleflightx
+
+import "context"
+
+// GoexitPolicy defines how the callers of a key are handled when the
+// function in charge of the key exits via runtime.Goexit.
+type GoexitPolicy int
+
+const (
+	// GoexitPropagate terminates the goroutine of every caller of the key,
+	// or reports the exit as an error if the group is detached.
+	GoexitPropagate GoexitPolicy = iota
+	// GoexitReelect hands the key to one of the callers that joined the
+	// in-flight call. Its own function is run on a goroutine owned by the
+	// group, while the other callers keep waiting. GoexitPropagate applies
+	// when no caller is left to be elected.
+	GoexitReelect
+)
+
+// candidate is a caller waiting for a call, that may be elected to run its
+// own function when the execution of the call exits via runtime.Goexit.
+type candidate[K comparable, V any] struct {
+	ctx context.Context
+	fn  func(context.Context, []K) (map[K]V, error)
+}
+
+// newCandidate returns the candidate of a caller waiting with ctx, or nil
+// if g does not re-elect leaders.
+func (g *Group[K, V]) newCandidate(ctx context.Context, fn func(context.Context, []K) (map[K]V, error)) *candidate[K, V] {
+	if g.GoexitPolicy != GoexitReelect {
+		return nil
+	}
+	return &candidate[K, V]{ctx: ctx, fn: fn}
+}
+
+// addCandidate registers cand on c, if not nil. It must be called with
+// the singleflight mutex held.
+func (c *call[K, V]) addCandidate(cand *candidate[K, V]) {
+	if cand != nil {
+		c.candidates = append(c.candidates, cand)
+	}
+}
+
+// elect pops the first candidate of c that still waits. It must be called
+// with the singleflight mutex held.
+func (c *call[K, V]) elect() *candidate[K, V] {
+	for len(c.candidates) > 0 {
+		cand := c.candidates[0]
+		c.candidates = c.candidates[1:]
+		if cand.ctx.Err() == nil {
+			return cand
+		}
+	}
+	return nil
+}
+
+// reelect hands the calls of keys, whose execution exited via
+// runtime.Goexit, to one of their candidates. It returns the keys that have
+// no candidate left. It must be called with the singleflight mutex held.
+func (g *Group[K, V]) reelect(calls map[K]*call[K, V], keys []K) (orphans []K) {
+	elected := map[*candidate[K, V]][]K{}
+	for _, k := range keys {
+		c := calls[k]
+		cand := c.elect()
+		if cand == nil {
+			orphans = append(orphans, k)
+			continue
+		}
+
+		c.err = nil
+		c.exec = nil
+		elected[cand] = append(elected[cand], k)
+	}
+
+	for cand, keys := range elected {
+		go g.doCallXContext(cand.ctx, calls, keys, cand.fn)
+	}
+
+	return orphans
+}
+
+func ignoreContext[K comparable, V any](fn func([]K) (map[K]V, error)) func(context.Context, []K) (map[K]V, error) {
+	return func(_ context.Context, keys []K) (map[K]V, error) {
+		return fn(keys)
+	}
+}
+
+func singleKey[K comparable, V any](fn func(context.Context) (V, error)) func(context.Context, []K) (map[K]V, error) {
+	return func(ctx context.Context, keys []K) (map[K]V, error) {
+		v, err := fn(ctx)
+		return map[K]V{keys[0]: v}, err
+	}
+}
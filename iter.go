@@ -0,0 +1,90 @@
+package singleflightx
+
+import (
+	"context"
+	"iter"
+)
+
+// DoXSeq is like DoX but returns an iterator over the results of keys, in
+// the order they complete. A key given many times is only yielded once.
+//
+// The calls are started each time the iteration starts. Stopping the
+// iteration early gives up waiting for the remaining keys, like a done
+// context with DoXContext: their calls are forgotten once nobody waits for
+// them anymore.
+func (g *Group[K, V]) DoXSeq(keys []K, fn func([]K) (map[K]V, error)) iter.Seq2[K, Result[V]] {
+	return func(yield func(K, Result[V]) bool) {
+		s := newResultStream[K, V](keys)
+		if s.left == 0 {
+			return
+		}
+
+		ctxFn := ignoreContext(fn)
+		calls, toCall := g.registerX(context.Background(), keys, nil, s, g.newCandidate(context.Background(), ctxFn))
+		defer func() {
+			if left := pendingCalls(calls, s.pending); len(left) > 0 {
+				g.leave(context.Background(), left...)
+			}
+		}()
+
+		g.startCallX(context.Background(), calls, toCall, ctxFn, funcID(fn))
+
+		s.all(yield)
+	}
+}
+
+// DoXSeq is like DoX but returns an iterator over the results of keys, in
+// the order they complete. See Group.DoXSeq. If the keys match different
+// shards, fn is called once per shard, concurrently.
+func (sg *ShardedGroup[K, V]) DoXSeq(keys []K, fn func([]K) (map[K]V, error)) iter.Seq2[K, Result[V]] {
+	return func(yield func(K, Result[V]) bool) {
+		s := newResultStream[K, V](keys)
+		if s.left == 0 {
+			return
+		}
+
+		ctxFn := ignoreContext(fn)
+		keysByShard := partitionBy(keys, func(key K) uint {
+			return sg.hasher.computeHash(key, sg.count)
+		})
+
+		calls := make(map[uint]map[K]*call[K, V], len(keysByShard))
+		defer func() {
+			for i, shardCalls := range calls {
+				if left := pendingCalls(shardCalls, s.pending); len(left) > 0 {
+					sg.shards[i].leave(context.Background(), left...)
+				}
+			}
+		}()
+
+		for i, keys := range keysByShard {
+			shardCalls, toCall := sg.shards[i].registerX(context.Background(), keys, nil, s, sg.shards[i].newCandidate(context.Background(), ctxFn))
+			calls[i] = shardCalls
+			sg.shards[i].startCallX(context.Background(), shardCalls, toCall, ctxFn, funcID(fn))
+		}
+
+		s.all(yield)
+	}
+}
+
+// all yields the results of s as they are received, until yield returns
+// false.
+func (s *resultStream[K, V]) all(yield func(K, Result[V]) bool) {
+	for r := range s.ch {
+		delete(s.pending, r.Key)
+		if !yield(r.Key, r.Result) {
+			return
+		}
+	}
+}
+
+// pendingCalls returns the calls of the pending keys.
+func pendingCalls[K comparable, V any](calls map[K]*call[K, V], pending map[K]struct{}) []*call[K, V] {
+	left := []*call[K, V]{}
+	for k, c := range calls {
+		if _, ok := pending[k]; ok {
+			left = append(left, c)
+		}
+	}
+	return left
+}
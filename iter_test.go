@@ -0,0 +1,125 @@
+package singleflightx
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoXSeq(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[string, int]{MaxBatchSize: 1}
+
+	release := make(chan struct{})
+	seq := g.DoXSeq([]string{"a", "b", "a"}, func(keys []string) (map[string]int, error) {
+		if keys[0] == "a" {
+			<-release
+			return map[string]int{"a": 1}, nil
+		}
+		return nil, errors.New("error")
+	})
+
+	keys := []string{}
+	for k, r := range seq {
+		keys = append(keys, k)
+		if k == "b" {
+			is.EqualError(r.Err, "error")
+			close(release)
+		} else {
+			is.Equal(1, r.Value.Value)
+		}
+	}
+	is.Equal([]string{"b", "a"}, keys)
+
+	for range g.DoXSeq(nil, func(keys []string) (map[string]int, error) {
+		t.Fatal("unexpected call")
+		return nil, nil
+	}) {
+		t.Fatal("unexpected result")
+	}
+}
+
+func TestDoXSeqBreak(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[string, int]{MaxBatchSize: 1}
+
+	release := make(chan struct{})
+	defer close(release)
+
+	for k := range g.DoXSeq([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+		if keys[0] == "a" {
+			<-release
+		}
+		return map[string]int{keys[0]: 1}, nil
+	}) {
+		is.Equal("b", k)
+		break
+	}
+
+	// nobody waits for "a" anymore
+	is.Eventually(func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.m["a"] == nil
+	}, time.Second, time.Millisecond)
+
+	v := g.DoX([]string{"a"}, func(keys []string) (map[string]int, error) {
+		return map[string]int{"a": 2}, nil
+	})
+	is.Equal(2, v["a"].Value.Value)
+}
+
+func TestDoXSeqGoexit(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+
+	keys := []string{}
+	for k, r := range g.DoXSeq([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+		runtime.Goexit()
+		return nil, nil
+	}) {
+		keys = append(keys, k)
+		is.ErrorIs(r.Err, ErrGoexit)
+	}
+	is.ElementsMatch([]string{"a", "b"}, keys)
+}
+
+func TestShardedGroupDoXSeq(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t)
+
+	results := map[int]int{}
+	for k, r := range sg.DoXSeq([]int{1, 2, 3, 5}, func(keys []int) (map[int]int, error) {
+		values := map[int]int{}
+		for _, k := range keys {
+			values[k] = k * 2
+		}
+		return values, nil
+	}) {
+		is.NoError(r.Err)
+		results[k] = r.Value.Value
+	}
+	is.Equal(map[int]int{1: 2, 2: 4, 3: 6, 5: 10}, results)
+
+	for range sg.DoXSeq([]int{1, 2, 3, 5}, func(keys []int) (map[int]int, error) {
+		return nil, nil
+	}) {
+		break
+	}
+
+	results = map[int]int{}
+	for k, r := range sg.DoXSeq([]int{6, 7}, func(keys []int) (map[int]int, error) {
+		runtime.Goexit()
+		return nil, nil
+	}) {
+		is.ErrorIs(r.Err, ErrGoexit)
+		results[k] = r.Value.Value
+	}
+	is.Len(results, 2)
+}
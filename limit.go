@@ -0,0 +1,41 @@
+package singleflightx
+
+import "context"
+
+// semaphore returns the semaphore bounding the number of concurrent
+// executions of g, or nil if unbounded. It is shared by the shards of a
+// ShardedGroup.
+func (g *Group[K, V]) semaphore() chan struct{} {
+	if g.MaxConcurrency <= 0 {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.sem == nil {
+		g.sem = make(chan struct{}, g.MaxConcurrency)
+	}
+	return g.sem
+}
+
+// limit returns fn, waiting for the number of concurrent executions of g to
+// drop below g.MaxConcurrency before each execution. The wait is given up
+// when the context passed to fn is done.
+func (g *Group[K, V]) limit(fn func(context.Context, []K) (map[K]V, error)) func(context.Context, []K) (map[K]V, error) {
+	sem := g.semaphore()
+	if sem == nil {
+		return fn
+	}
+
+	return func(ctx context.Context, keys []K) (map[K]V, error) {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-sem }()
+
+		return fn(ctx, keys)
+	}
+}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector holds the metrics of many groups, labeled by group name.
+type Collector struct {
+	durationBuckets  []float64
+	batchSizeBuckets []float64
+
+	mu     sync.Mutex // protects groups
+	groups map[string]*GroupMetrics
+}
+
+// NewCollector returns a collector using DefaultDurationBuckets and
+// DefaultBatchSizeBuckets.
+func NewCollector() *Collector {
+	return NewCollectorWithBuckets(DefaultDurationBuckets, DefaultBatchSizeBuckets)
+}
+
+// NewCollectorWithBuckets returns a collector using the given bucket upper
+// bounds for the execution latency, in seconds, and the batch size
+// histograms.
+func NewCollectorWithBuckets(durationBuckets []float64, batchSizeBuckets []float64) *Collector {
+	return &Collector{
+		durationBuckets:  durationBuckets,
+		batchSizeBuckets: batchSizeBuckets,
+		groups:           map[string]*GroupMetrics{},
+	}
+}
+
+// Group returns the metrics of the group of the given name, creating them
+// on first use.
+func (c *Collector) Group(name string) *GroupMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.groups[name]
+	if !ok {
+		m = &GroupMetrics{
+			BatchSize: NewHistogram(c.batchSizeBuckets),
+			Duration:  NewHistogram(c.durationBuckets),
+		}
+		c.groups[name] = m
+	}
+
+	return m
+}
+
+// WriteTo writes the metrics of every group to w, in the Prometheus text
+// exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.groups))
+	groups := make(map[string]*GroupMetrics, len(c.groups))
+	for name, m := range c.groups {
+		names = append(names, name)
+		groups[name] = m
+	}
+	c.mu.Unlock()
+	sort.Strings(names)
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	writeHeader(cw, "singleflightx_leader_executions_total", "counter", "Number of executions of the given functions.")
+	for _, name := range names {
+		writeSample(cw, "singleflightx_leader_executions_total", name, "", float64(groups[name].LeaderExecutions.Value()))
+	}
+
+	writeHeader(cw, "singleflightx_joined_callers_total", "counter", "Number of callers that joined an in-flight call.")
+	for _, name := range names {
+		writeSample(cw, "singleflightx_joined_callers_total", name, "", float64(groups[name].JoinedCallers.Value()))
+	}
+
+	writeHeader(cw, "singleflightx_panics_total", "counter", "Number of executions that panicked.")
+	for _, name := range names {
+		writeSample(cw, "singleflightx_panics_total", name, "", float64(groups[name].Panics.Value()))
+	}
+
+	writeHeader(cw, "singleflightx_inflight_keys", "gauge", "Number of keys currently being executed.")
+	for _, name := range names {
+		writeSample(cw, "singleflightx_inflight_keys", name, "", float64(groups[name].InFlightKeys.Value()))
+	}
+
+	writeHeader(cw, "singleflightx_batch_size", "histogram", "Number of keys passed to each execution.")
+	for _, name := range names {
+		writeHistogram(cw, "singleflightx_batch_size", name, groups[name].BatchSize)
+	}
+
+	writeHeader(cw, "singleflightx_execution_duration_seconds", "histogram", "Latency of each execution.")
+	for _, name := range names {
+		writeHistogram(cw, "singleflightx_execution_duration_seconds", name, groups[name].Duration)
+	}
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP writes the metrics of every group, so that the collector can be
+// scraped by Prometheus.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = c.WriteTo(w)
+}
+
+// countingWriter counts the bytes written to w, and remembers the first
+// error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) writeString(s string) {
+	if cw.err != nil {
+		return
+	}
+
+	n, err := cw.w.WriteString(s)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func writeHeader(cw *countingWriter, metric string, kind string, help string) {
+	cw.writeString("# HELP " + metric + " " + help + "\n")
+	cw.writeString("# TYPE " + metric + " " + kind + "\n")
+}
+
+func writeSample(cw *countingWriter, metric string, group string, le string, v float64) {
+	labels := `group="` + escapeLabel(group) + `"`
+	if le != "" {
+		labels += `,le="` + le + `"`
+	}
+
+	cw.writeString(metric + "{" + labels + "} " + formatFloat(v) + "\n")
+}
+
+func writeHistogram(cw *countingWriter, metric string, group string, h *Histogram) {
+	buckets, counts, count, sum := h.Snapshot()
+	for i, b := range buckets {
+		writeSample(cw, metric+"_bucket", group, formatFloat(b), float64(counts[i]))
+	}
+	writeSample(cw, metric+"_sum", group, "", sum)
+	writeSample(cw, metric+"_count", group, "", float64(count))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
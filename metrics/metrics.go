@@ -0,0 +1,129 @@
+// Package metrics collects the metrics of singleflightx groups, and writes
+// them in the Prometheus text exposition format, without requiring an
+// external client library.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultDurationBuckets are the upper bounds, in seconds, of the buckets of
+// the execution latency histograms.
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultBatchSizeBuckets are the upper bounds of the buckets of the batch
+// size histograms.
+var DefaultBatchSizeBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// Counter is a monotonically increasing value. The zero value is ready to
+// use.
+type Counter struct {
+	v uint64
+}
+
+// Inc increments the counter by 1.
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.v, 1)
+}
+
+// Add increments the counter by n.
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.v, n)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.v)
+}
+
+// Gauge is a value that can go up and down. The zero value is ready to use.
+type Gauge struct {
+	v int64
+}
+
+// Add adds n, which may be negative, to the gauge.
+func (g *Gauge) Add(n int64) {
+	atomic.AddInt64(&g.v, n)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.v)
+}
+
+// Histogram counts observations in buckets of configurable upper bounds.
+type Histogram struct {
+	buckets []float64 // sorted upper bounds, without +Inf
+
+	mu     sync.Mutex // protects following fields
+	counts []uint64   // not cumulative, the last one is +Inf
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram returns a histogram with the given bucket upper bounds. A
+// +Inf bucket is always added.
+func NewHistogram(buckets []float64) *Histogram {
+	bounds := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		if !math.IsInf(b, 1) {
+			bounds = append(bounds, b)
+		}
+	}
+	sort.Float64s(bounds)
+
+	return &Histogram{
+		buckets: bounds,
+		counts:  make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe adds a single observation to the histogram.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.mu.Lock()
+	h.counts[i]++
+	h.count++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// Snapshot returns the bucket upper bounds, the cumulative count of each
+// bucket, the total count and the sum of the observations. The +Inf bucket
+// is last.
+func (h *Histogram) Snapshot() (buckets []float64, counts []uint64, count uint64, sum float64) {
+	buckets = append(append([]float64{}, h.buckets...), math.Inf(1))
+	counts = make([]uint64, len(h.counts))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var cumulative uint64
+	for i, n := range h.counts {
+		cumulative += n
+		counts[i] = cumulative
+	}
+
+	return buckets, counts, h.count, h.sum
+}
+
+// GroupMetrics holds the metrics of a single group.
+type GroupMetrics struct {
+	// LeaderExecutions counts the executions of the given functions.
+	LeaderExecutions Counter
+	// JoinedCallers counts the callers that joined an in-flight call
+	// instead of executing their own function.
+	JoinedCallers Counter
+	// Panics counts the executions that panicked.
+	Panics Counter
+	// InFlightKeys is the number of keys currently being executed.
+	InFlightKeys Gauge
+	// BatchSize observes the number of keys passed to each execution.
+	BatchSize *Histogram
+	// Duration observes the latency of each execution, in seconds.
+	Duration *Histogram
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterAndGauge(t *testing.T) {
+	is := assert.New(t)
+
+	var c Counter
+	c.Inc()
+	c.Add(2)
+	is.Equal(uint64(3), c.Value())
+
+	var g Gauge
+	g.Add(3)
+	g.Add(-1)
+	is.Equal(int64(2), g.Value())
+}
+
+func TestHistogram(t *testing.T) {
+	is := assert.New(t)
+
+	h := NewHistogram([]float64{10, 1, math.Inf(1)})
+	h.Observe(0.5)
+	h.Observe(1)
+	h.Observe(5)
+	h.Observe(100)
+
+	buckets, counts, count, sum := h.Snapshot()
+	is.Equal([]float64{1, 10, math.Inf(1)}, buckets)
+	is.Equal([]uint64{2, 3, 4}, counts)
+	is.Equal(uint64(4), count)
+	is.Equal(106.5, sum)
+}
+
+func TestCollectorWriteTo(t *testing.T) {
+	is := assert.New(t)
+
+	c := NewCollectorWithBuckets([]float64{1}, []float64{1, 10})
+	is.Same(c.Group("users"), c.Group("users"))
+
+	m := c.Group("users")
+	m.LeaderExecutions.Add(2)
+	m.JoinedCallers.Inc()
+	m.InFlightKeys.Add(3)
+	m.BatchSize.Observe(3)
+	m.Duration.Observe(0.5)
+	c.Group(`a"b`).Panics.Inc()
+
+	var buf bytes.Buffer
+	n, err := c.WriteTo(&buf)
+	is.NoError(err)
+	is.Equal(int64(buf.Len()), n)
+
+	expected := strings.Join([]string{
+		`# HELP singleflightx_leader_executions_total Number of executions of the given functions.`,
+		`# TYPE singleflightx_leader_executions_total counter`,
+		`singleflightx_leader_executions_total{group="a\"b"} 0`,
+		`singleflightx_leader_executions_total{group="users"} 2`,
+		`# HELP singleflightx_joined_callers_total Number of callers that joined an in-flight call.`,
+		`# TYPE singleflightx_joined_callers_total counter`,
+		`singleflightx_joined_callers_total{group="a\"b"} 0`,
+		`singleflightx_joined_callers_total{group="users"} 1`,
+		`# HELP singleflightx_panics_total Number of executions that panicked.`,
+		`# TYPE singleflightx_panics_total counter`,
+		`singleflightx_panics_total{group="a\"b"} 1`,
+		`singleflightx_panics_total{group="users"} 0`,
+		`# HELP singleflightx_inflight_keys Number of keys currently being executed.`,
+		`# TYPE singleflightx_inflight_keys gauge`,
+		`singleflightx_inflight_keys{group="a\"b"} 0`,
+		`singleflightx_inflight_keys{group="users"} 3`,
+		`# HELP singleflightx_batch_size Number of keys passed to each execution.`,
+		`# TYPE singleflightx_batch_size histogram`,
+		`singleflightx_batch_size_bucket{group="a\"b",le="1"} 0`,
+		`singleflightx_batch_size_bucket{group="a\"b",le="10"} 0`,
+		`singleflightx_batch_size_bucket{group="a\"b",le="+Inf"} 0`,
+		`singleflightx_batch_size_sum{group="a\"b"} 0`,
+		`singleflightx_batch_size_count{group="a\"b"} 0`,
+		`singleflightx_batch_size_bucket{group="users",le="1"} 0`,
+		`singleflightx_batch_size_bucket{group="users",le="10"} 1`,
+		`singleflightx_batch_size_bucket{group="users",le="+Inf"} 1`,
+		`singleflightx_batch_size_sum{group="users"} 3`,
+		`singleflightx_batch_size_count{group="users"} 1`,
+		`# HELP singleflightx_execution_duration_seconds Latency of each execution.`,
+		`# TYPE singleflightx_execution_duration_seconds histogram`,
+		`singleflightx_execution_duration_seconds_bucket{group="a\"b",le="1"} 0`,
+		`singleflightx_execution_duration_seconds_bucket{group="a\"b",le="+Inf"} 0`,
+		`singleflightx_execution_duration_seconds_sum{group="a\"b"} 0`,
+		`singleflightx_execution_duration_seconds_count{group="a\"b"} 0`,
+		`singleflightx_execution_duration_seconds_bucket{group="users",le="1"} 1`,
+		`singleflightx_execution_duration_seconds_bucket{group="users",le="+Inf"} 1`,
+		`singleflightx_execution_duration_seconds_sum{group="users"} 0.5`,
+		`singleflightx_execution_duration_seconds_count{group="users"} 1`,
+		``,
+	}, "\n")
+	is.Equal(expected, buf.String())
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/samber/go-singleflightx"
+)
+
+var _ singleflightx.Observer[string] = (*Observer[string])(nil)
+
+// Observer records the lifecycle of the calls of a group into its
+// GroupMetrics. It implements singleflightx.Observer.
+type Observer[K comparable] struct {
+	metrics *GroupMetrics
+}
+
+// NewObserver returns an observer recording into the metrics of the group
+// of the given name in c.
+func NewObserver[K comparable](c *Collector, group string) *Observer[K] {
+	return &Observer[K]{metrics: c.Group(group)}
+}
+
+// OnLeaderStart implements singleflightx.Observer.
+func (o *Observer[K]) OnLeaderStart(keys []K) {
+	o.metrics.LeaderExecutions.Inc()
+	o.metrics.BatchSize.Observe(float64(len(keys)))
+	o.metrics.InFlightKeys.Add(int64(len(keys)))
+}
+
+// OnJoin implements singleflightx.Observer.
+func (o *Observer[K]) OnJoin(key K) {
+	o.metrics.JoinedCallers.Inc()
+}
+
+// OnComplete implements singleflightx.Observer.
+func (o *Observer[K]) OnComplete(keys []K, err error, duration time.Duration, dups int) {
+	o.metrics.InFlightKeys.Add(-int64(len(keys)))
+	o.metrics.Duration.Observe(duration.Seconds())
+}
+
+// OnPanic implements singleflightx.Observer.
+func (o *Observer[K]) OnPanic(keys []K, value interface{}) {
+	o.metrics.Panics.Inc()
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/samber/go-singleflightx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserver(t *testing.T) {
+	is := assert.New(t)
+
+	c := NewCollector()
+	g, err := singleflightx.NewGroup[string, int](
+		singleflightx.WithObserver[string](NewObserver[string](c, "users")),
+		singleflightx.WithPanicPolicy(singleflightx.PanicAsError),
+	)
+	is.NoError(err)
+
+	results := g.DoX([]string{"a", "b", "c"}, func(keys []string) (map[string]int, error) {
+		_, _, shared := g.Do("d", func() (int, error) {
+			return 4, nil
+		})
+		is.False(shared)
+		return map[string]int{"a": 1, "b": 2, "c": 3}, nil
+	})
+	is.Len(results, 3)
+
+	_, err, _ = g.Do("e", func() (int, error) {
+		panic("boom")
+	})
+	is.Error(err)
+
+	m := c.Group("users")
+	is.Equal(uint64(3), m.LeaderExecutions.Value())
+	is.Equal(uint64(0), m.JoinedCallers.Value())
+	is.Equal(uint64(1), m.Panics.Value())
+	is.Equal(int64(0), m.InFlightKeys.Value())
+
+	_, counts, count, sum := m.BatchSize.Snapshot()
+	is.Equal(uint64(3), count)
+	is.Equal(float64(5), sum)
+	is.Equal(uint64(2), counts[0])
+
+	_, _, count, _ = m.Duration.Snapshot()
+	is.Equal(uint64(3), count)
+}
+
+func TestObserverJoin(t *testing.T) {
+	is := assert.New(t)
+
+	c := NewCollector()
+	g := singleflightx.Group[string, int]{Observer: NewObserver[string](c, "users")}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ch := g.DoChan("key", func() (int, error) {
+		close(started)
+		<-release
+		return 42, nil
+	})
+	<-started
+
+	joined := g.DoChan("key", func() (int, error) {
+		return 0, nil
+	})
+	is.Equal(int64(1), c.Group("users").InFlightKeys.Value())
+	close(release)
+
+	is.Equal(42, (<-ch).Value.Value)
+	is.Equal(42, (<-joined).Value.Value)
+	is.Equal(uint64(1), c.Group("users").LeaderExecutions.Value())
+	is.Equal(uint64(1), c.Group("users").JoinedCallers.Value())
+}
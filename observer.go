@@ -0,0 +1,52 @@
+package singleflightx
+
+import "time"
+
+// Observer is notified of the lifecycle of the calls of a group. Its
+// methods are invoked synchronously, outside of the group mutex, and must
+// be safe for concurrent use.
+type Observer[K comparable] interface {
+	// OnLeaderStart is called before the given function is executed for
+	// keys.
+	OnLeaderStart(keys []K)
+	// OnJoin is called when a caller joins the in-flight call of key,
+	// instead of executing its own function.
+	OnJoin(key K)
+	// OnComplete is called once the execution of the given function for
+	// keys is over. err is the error of the whole execution, dups is the
+	// number of callers that joined the calls of keys.
+	OnComplete(keys []K, err error, duration time.Duration, dups int)
+	// OnPanic is called when the given function panics, before OnComplete.
+	OnPanic(keys []K, value interface{})
+}
+
+func (g *Group[K, V]) observeStart(keys []K) time.Time {
+	if g.Observer == nil {
+		return time.Time{}
+	}
+
+	g.Observer.OnLeaderStart(keys)
+	return time.Now()
+}
+
+func (g *Group[K, V]) observeJoin(keys ...K) {
+	if g.Observer == nil {
+		return
+	}
+
+	for _, key := range keys {
+		g.Observer.OnJoin(key)
+	}
+}
+
+func (g *Group[K, V]) observePanic(keys []K, e *PanicError) {
+	if g.Observer != nil {
+		g.Observer.OnPanic(keys, e.value)
+	}
+}
+
+func (g *Group[K, V]) observeComplete(keys []K, err error, start time.Time, dups int) {
+	if g.Observer != nil {
+		g.Observer.OnComplete(keys, err, time.Since(start), dups)
+	}
+}
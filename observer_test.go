@@ -0,0 +1,168 @@
+package singleflightx
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type observedCompletion[K comparable] struct {
+	keys []K
+	err  error
+	dups int
+}
+
+type recordingObserver[K comparable] struct {
+	mu          sync.Mutex
+	starts      [][]K
+	joins       []K
+	completions []observedCompletion[K]
+	panics      []interface{}
+}
+
+func (o *recordingObserver[K]) OnLeaderStart(keys []K) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.starts = append(o.starts, keys)
+}
+
+func (o *recordingObserver[K]) OnJoin(key K) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.joins = append(o.joins, key)
+}
+
+func (o *recordingObserver[K]) OnComplete(keys []K, err error, duration time.Duration, dups int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.completions = append(o.completions, observedCompletion[K]{keys, err, dups})
+}
+
+func (o *recordingObserver[K]) OnPanic(keys []K, value interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.panics = append(o.panics, value)
+}
+
+func (o *recordingObserver[K]) completed(n int) func() bool {
+	return func() bool {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return len(o.completions) == n
+	}
+}
+
+func TestObserverDo(t *testing.T) {
+	is := assert.New(t)
+
+	observer := &recordingObserver[string]{}
+	g := Group[string, int]{Observer: observer}
+
+	v, err, _ := g.Do("key", func() (int, error) {
+		return 42, nil
+	})
+	is.NoError(err)
+	is.Equal(42, v)
+
+	someErr := errors.New("error")
+	_, err, _ = g.Do("key", func() (int, error) {
+		return 0, someErr
+	})
+	is.Equal(someErr, err)
+
+	is.Equal([][]string{{"key"}, {"key"}}, observer.starts)
+	is.Empty(observer.joins)
+	is.Equal([]observedCompletion[string]{{[]string{"key"}, nil, 0}, {[]string{"key"}, someErr, 0}}, observer.completions)
+	is.Empty(observer.panics)
+}
+
+func TestObserverDoJoin(t *testing.T) {
+	is := assert.New(t)
+
+	observer := &recordingObserver[string]{}
+	g := Group[string, int]{Observer: observer}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_, _, _ = g.Do("key", func() (int, error) {
+			close(started)
+			<-release
+			return 42, nil
+		})
+	}()
+	<-started
+
+	ch := g.DoChan("key", func() (int, error) {
+		return 0, nil
+	})
+	close(release)
+
+	res := <-ch
+	is.Equal(42, res.Value.Value)
+	is.True(res.Shared)
+	is.Eventually(observer.completed(1), time.Second, time.Millisecond)
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	is.Equal([][]string{{"key"}}, observer.starts)
+	is.Equal([]string{"key"}, observer.joins)
+	is.Equal([]observedCompletion[string]{{[]string{"key"}, nil, 1}}, observer.completions)
+}
+
+func TestObserverDoX(t *testing.T) {
+	is := assert.New(t)
+
+	observer := &recordingObserver[string]{}
+	g := Group[string, int]{Observer: observer}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_ = g.DoX([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+			close(started)
+			<-release
+			return map[string]int{"a": 1, "b": 2}, nil
+		})
+	}()
+	<-started
+
+	chans := g.DoChanX([]string{"b", "c"}, func(keys []string) (map[string]int, error) {
+		return map[string]int{"c": 3}, nil
+	})
+	is.Equal(3, (<-chans["c"]).Value.Value)
+	close(release)
+	is.Equal(2, (<-chans["b"]).Value.Value)
+	is.Eventually(observer.completed(2), time.Second, time.Millisecond)
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	is.ElementsMatch([][]string{{"a", "b"}, {"c"}}, observer.starts)
+	is.Equal([]string{"b"}, observer.joins)
+	is.ElementsMatch([]observedCompletion[string]{{[]string{"c"}, nil, 0}, {[]string{"a", "b"}, nil, 1}}, observer.completions)
+}
+
+func TestObserverPanic(t *testing.T) {
+	is := assert.New(t)
+
+	observer := &recordingObserver[string]{}
+	g := Group[string, int]{Observer: observer, PanicPolicy: PanicAsError}
+
+	_, err, _ := g.Do("key", func() (int, error) {
+		panic("boom")
+	})
+	is.IsType(&PanicError{}, err)
+
+	results := g.DoX([]string{"a"}, func(keys []string) (map[string]int, error) {
+		panic("bang")
+	})
+	is.IsType(&PanicError{}, results["a"].Err)
+
+	is.Equal([]interface{}{"boom", "bang"}, observer.panics)
+	is.Len(observer.completions, 2)
+	is.Equal(err, observer.completions[0].err)
+	is.Equal(results["a"].Err, observer.completions[1].err)
+}
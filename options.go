@@ -0,0 +1,390 @@
+package singleflightx
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Option configures a Group or a ShardedGroup. See NewGroup and
+// NewShardedGroup.
+type Option func(*config) error
+
+type config struct {
+	detached       bool
+	goexitPolicy   GoexitPolicy
+	panicPolicy    PanicPolicy
+	failurePolicy  FailurePolicy
+	observer       interface{} // Observer[K]
+	tracer         interface{} // Tracer[K]
+	maxBatchSize   int
+	maxConcurrency int
+	mergeDelay     time.Duration
+	absentPolicy   AbsentPolicy
+	absentDefault  interface{}   // V
+	sem            chan struct{} // shared by every shard, see WithMaxConcurrency
+
+	// Only supported by ShardedGroup.
+	shards uint
+	hasher interface{} // Hasher[K]
+
+	// Only supported by Batcher.
+	maxWait time.Duration
+	maxSize int
+}
+
+func newConfig(opts []Option) (*config, error) {
+	cfg := &config{}
+	for _, opt := range opts {
+		if opt == nil {
+			return nil, errors.New("singleflightx: nil option")
+		}
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.maxConcurrency > 0 {
+		cfg.sem = make(chan struct{}, cfg.maxConcurrency)
+	}
+	return cfg, nil
+}
+
+// configure applies cfg to g.
+func configure[K comparable, V any](g *Group[K, V], cfg *config) error {
+	g.Detached = cfg.detached
+	g.GoexitPolicy = cfg.goexitPolicy
+	g.PanicPolicy = cfg.panicPolicy
+	g.FailurePolicy = cfg.failurePolicy
+	g.MaxBatchSize = cfg.maxBatchSize
+	g.MaxConcurrency = cfg.maxConcurrency
+	g.MergeDelay = cfg.mergeDelay
+	g.AbsentPolicy = cfg.absentPolicy
+	g.sem = cfg.sem
+
+	if cfg.absentDefault != nil {
+		def, ok := cfg.absentDefault.(V)
+		if !ok {
+			var v V
+			return fmt.Errorf("singleflightx: the absent default is not of type %T", v)
+		}
+		g.AbsentDefault = def
+	}
+
+	if cfg.observer != nil {
+		observer, ok := cfg.observer.(Observer[K])
+		if !ok {
+			var k K
+			return fmt.Errorf("singleflightx: the observer does not accept keys of type %T", k)
+		}
+		g.Observer = observer
+	}
+
+	if cfg.tracer != nil {
+		tracer, ok := cfg.tracer.(Tracer[K])
+		if !ok {
+			var k K
+			return fmt.Errorf("singleflightx: the tracer does not accept keys of type %T", k)
+		}
+		g.Tracer = tracer
+	}
+
+	return nil
+}
+
+// NewGroup returns a Group configured with opts. An error is returned if
+// the configuration is invalid.
+//
+// The zero value of Group is ready to use as well, with the default
+// configuration.
+func NewGroup[K comparable, V any](opts ...Option) (*Group[K, V], error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.shards != 0 || cfg.hasher != nil {
+		return nil, errors.New("singleflightx: WithShards and WithHasher are only supported by NewShardedGroup")
+	}
+	if cfg.maxWait != 0 {
+		return nil, errors.New("singleflightx: WithBatchWindow is only supported by NewBatcher")
+	}
+
+	g := &Group[K, V]{}
+	if err := configure(g, cfg); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// WithDetached makes the given functions always run on a goroutine owned by
+// the group. See Group.Detached.
+func WithDetached() Option {
+	return func(cfg *config) error {
+		cfg.detached = true
+		return nil
+	}
+}
+
+// WithGoexitPolicy sets how callers are handled when the given function
+// exits via runtime.Goexit. See Group.GoexitPolicy.
+func WithGoexitPolicy(policy GoexitPolicy) Option {
+	return func(cfg *config) error {
+		if policy != GoexitPropagate && policy != GoexitReelect {
+			return fmt.Errorf("singleflightx: invalid goexit policy %d", policy)
+		}
+		cfg.goexitPolicy = policy
+		return nil
+	}
+}
+
+// WithPanicPolicy sets how callers are handled when the given function
+// panics. See Group.PanicPolicy.
+func WithPanicPolicy(policy PanicPolicy) Option {
+	return func(cfg *config) error {
+		if policy != PanicCrash && policy != PanicRepanic && policy != PanicAsError {
+			return fmt.Errorf("singleflightx: invalid panic policy %d", policy)
+		}
+		cfg.panicPolicy = policy
+		return nil
+	}
+}
+
+// WithFailurePolicy sets how the keys of a batch are handled when the
+// given function fails. See Group.FailurePolicy.
+func WithFailurePolicy(policy FailurePolicy) Option {
+	return func(cfg *config) error {
+		if policy != FailBatch && policy != FailBisect {
+			return fmt.Errorf("singleflightx: invalid failure policy %d", policy)
+		}
+		cfg.failurePolicy = policy
+		return nil
+	}
+}
+
+// WithObserver sets the observer notified of the lifecycle of every call.
+// Its key type must match the one of the group. See Group.Observer.
+func WithObserver[K comparable](observer Observer[K]) Option {
+	return func(cfg *config) error {
+		if observer == nil {
+			return errors.New("singleflightx: nil observer")
+		}
+		cfg.observer = observer
+		return nil
+	}
+}
+
+// WithTracer sets the tracer notified of every execution and of the callers
+// joining it. Its key type must match the one of the group. See
+// Group.Tracer.
+func WithTracer[K comparable](tracer Tracer[K]) Option {
+	return func(cfg *config) error {
+		if tracer == nil {
+			return errors.New("singleflightx: nil tracer")
+		}
+		cfg.tracer = tracer
+		return nil
+	}
+}
+
+// WithMaxBatchSize sets the maximum number of keys passed to a single
+// invocation of the functions given to DoX, DoXContext and DoChanX. It must
+// be greater than zero. See Group.MaxBatchSize.
+func WithMaxBatchSize(size int) Option {
+	return func(cfg *config) error {
+		if size <= 0 {
+			return errors.New("singleflightx: the maximum batch size must be greater than zero")
+		}
+		cfg.maxBatchSize = size
+		return nil
+	}
+}
+
+// WithMaxConcurrency sets the maximum number of functions given to DoX,
+// DoXContext and DoChanX executing at once. It must be greater than zero.
+// The limit is shared by every shard of a ShardedGroup. See
+// Group.MaxConcurrency.
+func WithMaxConcurrency(limit int) Option {
+	return func(cfg *config) error {
+		if limit <= 0 {
+			return errors.New("singleflightx: the maximum concurrency must be greater than zero")
+		}
+		cfg.maxConcurrency = limit
+		return nil
+	}
+}
+
+// WithMergeDelay sets how long the keys given to DoX, DoXContext and
+// DoChanX wait to be merged with the keys of other callers of the same
+// func value, before being executed. It must be greater than zero. See
+// Group.MergeDelay.
+func WithMergeDelay(delay time.Duration) Option {
+	return func(cfg *config) error {
+		if delay <= 0 {
+			return errors.New("singleflightx: the merge delay must be greater than zero")
+		}
+		cfg.mergeDelay = delay
+		return nil
+	}
+}
+
+// WithAbsentPolicy sets how the keys absent from the results of the
+// functions given to DoX, DoXContext, DoXResults and DoChanX are reported.
+// See Group.AbsentPolicy.
+func WithAbsentPolicy(policy AbsentPolicy) Option {
+	return func(cfg *config) error {
+		if err := validateAbsentPolicy(policy); err != nil {
+			return err
+		}
+		cfg.absentPolicy = policy
+		return nil
+	}
+}
+
+// WithAbsentDefault reports the keys absent from the results of the
+// functions given to DoX, DoXContext, DoXResults and DoChanX with value. Its
+// type must match the value type of the group. See Group.AbsentDefault.
+func WithAbsentDefault[V any](value V) Option {
+	return func(cfg *config) error {
+		cfg.absentPolicy = AbsentDefault
+		cfg.absentDefault = value
+		return nil
+	}
+}
+
+// WithShards sets the number of shards of a ShardedGroup. It must be
+// greater than zero.
+func WithShards(count uint) Option {
+	return func(cfg *config) error {
+		if count == 0 {
+			return errors.New("singleflightx: the number of shards must be greater than zero")
+		}
+		cfg.shards = count
+		return nil
+	}
+}
+
+// WithHasher sets the function mapping keys to the shards of a
+// ShardedGroup. Its key type must match the one of the group.
+func WithHasher[K any](hasher Hasher[K]) Option {
+	return func(cfg *config) error {
+		if hasher == nil {
+			return errors.New("singleflightx: nil hasher")
+		}
+		cfg.hasher = hasher
+		return nil
+	}
+}
+
+// WithBatchWindow sets the window over which a Batcher collects keys:
+// a batch is executed once its first key has waited for maxWait, or as soon
+// as it holds maxSize keys. maxWait must be greater than zero. A zero
+// maxSize does not bound the size of the batches.
+func WithBatchWindow(maxWait time.Duration, maxSize int) Option {
+	return func(cfg *config) error {
+		if maxWait <= 0 {
+			return errors.New("singleflightx: the maximum wait of the batch window must be greater than zero")
+		}
+		if maxSize < 0 {
+			return errors.New("singleflightx: the maximum size of the batch window must not be negative")
+		}
+		cfg.maxWait = maxWait
+		cfg.maxSize = maxSize
+		return nil
+	}
+}
+
+// CallOption configures a single call of DoX, DoXContext or DoXResults.
+type CallOption func(*callConfig) error
+
+type callConfig struct {
+	fallback      interface{} // func(K) (V, error)
+	absent        bool
+	absentPolicy  AbsentPolicy
+	absentDefault interface{} // V
+}
+
+// callOptions is the configuration of a single call, with the types of its
+// group. absent reports whether the absent policy of the group is
+// overridden.
+type callOptions[K comparable, V any] struct {
+	fallback      func(K) (V, error)
+	absent        bool
+	absentPolicy  AbsentPolicy
+	absentDefault V
+}
+
+func newCallOptions[K comparable, V any](opts []CallOption) (callOptions[K, V], error) {
+	cfg := &callConfig{}
+	for _, opt := range opts {
+		if opt == nil {
+			return callOptions[K, V]{}, errors.New("singleflightx: nil call option")
+		}
+		if err := opt(cfg); err != nil {
+			return callOptions[K, V]{}, err
+		}
+	}
+
+	co := callOptions[K, V]{}
+	if cfg.fallback != nil {
+		fallback, ok := cfg.fallback.(func(K) (V, error))
+		if !ok {
+			var k K
+			var v V
+			return co, fmt.Errorf("singleflightx: the fallback is not a func(%T) (%T, error)", k, v)
+		}
+		co.fallback = fallback
+	}
+
+	co.absent = cfg.absent
+	co.absentPolicy = cfg.absentPolicy
+	if cfg.absentDefault != nil {
+		def, ok := cfg.absentDefault.(V)
+		if !ok {
+			var v V
+			return co, fmt.Errorf("singleflightx: the absent default is not of type %T", v)
+		}
+		co.absentDefault = def
+	}
+
+	return co, nil
+}
+
+// WithFallback sets a function resolving, one by one, the keys that are
+// absent from the results of the batch function. The calls of the fallback
+// are deduplicated through the same group, like Do: callers asking for an
+// absent key at the same time share a single call of the fallback. Its
+// types must match the ones of the group.
+func WithFallback[K comparable, V any](fallback func(K) (V, error)) CallOption {
+	return func(cfg *callConfig) error {
+		if fallback == nil {
+			return errors.New("singleflightx: nil fallback")
+		}
+		cfg.fallback = fallback
+		return nil
+	}
+}
+
+// WithCallAbsentPolicy overrides, for this call only, how the keys absent
+// from the results of the function are reported. See Group.AbsentPolicy.
+func WithCallAbsentPolicy(policy AbsentPolicy) CallOption {
+	return func(cfg *callConfig) error {
+		if err := validateAbsentPolicy(policy); err != nil {
+			return err
+		}
+		cfg.absent = true
+		cfg.absentPolicy = policy
+		cfg.absentDefault = nil
+		return nil
+	}
+}
+
+// WithCallAbsentDefault reports, for this call only, the keys absent from
+// the results of the function with value. Its type must match the value
+// type of the group.
+func WithCallAbsentDefault[V any](value V) CallOption {
+	return func(cfg *callConfig) error {
+		cfg.absent = true
+		cfg.absentPolicy = AbsentDefault
+		cfg.absentDefault = value
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package singleflightx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewGroup(t *testing.T) {
+	is := assert.New(t)
+
+	g, err := NewGroup[string, int]()
+	is.NoError(err)
+	is.False(g.Detached)
+	is.Equal(GoexitPropagate, g.GoexitPolicy)
+	is.Equal(PanicCrash, g.PanicPolicy)
+
+	g, err = NewGroup[string, int](WithDetached(), WithGoexitPolicy(GoexitReelect), WithPanicPolicy(PanicAsError), WithMaxBatchSize(10), WithMaxConcurrency(4), WithMergeDelay(time.Millisecond), WithFailurePolicy(FailBisect))
+	is.NoError(err)
+	is.Equal(10, g.MaxBatchSize)
+	is.Equal(4, g.MaxConcurrency)
+	is.Equal(time.Millisecond, g.MergeDelay)
+	is.Equal(FailBisect, g.FailurePolicy)
+	is.True(g.Detached)
+	is.Equal(GoexitReelect, g.GoexitPolicy)
+	is.Equal(PanicAsError, g.PanicPolicy)
+
+	v, err, _ := g.Do("key", func() (int, error) {
+		return 42, nil
+	})
+	is.NoError(err)
+	is.Equal(42, v)
+
+	_, err = NewGroup[string, int](WithPanicPolicy(PanicPolicy(42)))
+	is.EqualError(err, "singleflightx: invalid panic policy 42")
+	_, err = NewGroup[string, int](WithGoexitPolicy(GoexitPolicy(-1)))
+	is.EqualError(err, "singleflightx: invalid goexit policy -1")
+	_, err = NewGroup[string, int](nil)
+	is.EqualError(err, "singleflightx: nil option")
+	_, err = NewGroup[string, int](WithMaxBatchSize(0))
+	is.EqualError(err, "singleflightx: the maximum batch size must be greater than zero")
+	_, err = NewGroup[string, int](WithMaxConcurrency(-1))
+	is.EqualError(err, "singleflightx: the maximum concurrency must be greater than zero")
+	_, err = NewGroup[string, int](WithMergeDelay(0))
+	is.EqualError(err, "singleflightx: the merge delay must be greater than zero")
+	_, err = NewGroup[string, int](WithFailurePolicy(FailurePolicy(2)))
+	is.EqualError(err, "singleflightx: invalid failure policy 2")
+	_, err = NewGroup[string, int](WithShards(2))
+	is.EqualError(err, "singleflightx: WithShards and WithHasher are only supported by NewShardedGroup")
+	_, err = NewGroup[string, int](WithAbsentPolicy(AbsentPolicy(3)))
+	is.EqualError(err, "singleflightx: invalid absent policy 3")
+	_, err = NewGroup[string, int](WithAbsentDefault("zero"))
+	is.EqualError(err, "singleflightx: the absent default is not of type int")
+
+	g, err = NewGroup[string, int](WithAbsentDefault(-1))
+	is.NoError(err)
+	is.Equal(AbsentDefault, g.AbsentPolicy)
+	is.Equal(-1, g.AbsentDefault)
+}
+
+func TestNewGroupWithObserver(t *testing.T) {
+	is := assert.New(t)
+
+	observer := &recordingObserver[string]{}
+	g, err := NewGroup[string, int](WithObserver[string](observer))
+	is.NoError(err)
+	is.Equal(observer, g.Observer)
+
+	_, err = NewGroup[int, int](WithObserver[string](observer))
+	is.EqualError(err, "singleflightx: the observer does not accept keys of type int")
+	_, err = NewGroup[string, int](WithObserver[string](nil))
+	is.EqualError(err, "singleflightx: nil observer")
+}
+
+func TestNewGroupWithTracer(t *testing.T) {
+	is := assert.New(t)
+
+	tracer := &recordingTracer[string]{}
+	g, err := NewGroup[string, int](WithTracer[string](tracer))
+	is.NoError(err)
+	is.Equal(tracer, g.Tracer)
+
+	_, err = NewGroup[int, int](WithTracer[string](tracer))
+	is.EqualError(err, "singleflightx: the tracer does not accept keys of type int")
+	_, err = NewGroup[string, int](WithTracer[string](nil))
+	is.EqualError(err, "singleflightx: nil tracer")
+}
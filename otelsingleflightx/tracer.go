@@ -0,0 +1,123 @@
+// Package otelsingleflightx traces the executions of singleflightx groups
+// with OpenTelemetry. Each execution of a given function is recorded as a
+// span, and the span of every caller that joined it is linked to it.
+package otelsingleflightx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/go-singleflightx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of the tracer.
+const ScopeName = "github.com/samber/go-singleflightx/otelsingleflightx"
+
+// DefaultSpanName is the name of the execution spans.
+const DefaultSpanName = "singleflightx.execution"
+
+const (
+	keysAttribute      = attribute.Key("singleflightx.keys")
+	keysCountAttribute = attribute.Key("singleflightx.keys.count")
+	keyAttribute       = attribute.Key("singleflightx.key")
+	joinEventName      = "singleflightx.join"
+)
+
+var _ singleflightx.Tracer[string] = (*Tracer[string])(nil)
+
+type config struct {
+	provider trace.TracerProvider
+	spanName string
+}
+
+// Option configures a Tracer.
+type Option func(*config)
+
+// WithTracerProvider sets the provider of the OpenTelemetry tracer. Defaults
+// to the global provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(cfg *config) {
+		cfg.provider = provider
+	}
+}
+
+// WithSpanName sets the name of the execution spans. Defaults to
+// DefaultSpanName.
+func WithSpanName(name string) Option {
+	return func(cfg *config) {
+		cfg.spanName = name
+	}
+}
+
+// Tracer records the executions of a group as OpenTelemetry spans. It
+// implements singleflightx.Tracer.
+type Tracer[K comparable] struct {
+	tracer   trace.Tracer
+	spanName string
+}
+
+// NewTracer returns a tracer configured with opts.
+func NewTracer[K comparable](opts ...Option) *Tracer[K] {
+	cfg := &config{
+		provider: otel.GetTracerProvider(),
+		spanName: DefaultSpanName,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &Tracer[K]{
+		tracer:   cfg.provider.Tracer(ScopeName),
+		spanName: cfg.spanName,
+	}
+}
+
+// StartExecution implements singleflightx.Tracer. The execution span is a
+// child of the span of ctx, if any.
+func (t *Tracer[K]) StartExecution(ctx context.Context, keys []K) (context.Context, func(error)) {
+	ctx, span := t.tracer.Start(
+		ctx,
+		t.spanName,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			keysAttribute.StringSlice(formatKeys(keys)),
+			keysCountAttribute.Int(len(keys)),
+		),
+	)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Join implements singleflightx.Tracer. The span of ctx, if any, is linked
+// to the execution span, and records a join event.
+func (t *Tracer[K]) Join(ctx context.Context, execCtx context.Context, key K) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := []attribute.KeyValue{keyAttribute.String(fmt.Sprint(key))}
+	span.AddLink(trace.Link{
+		SpanContext: trace.SpanContextFromContext(execCtx),
+		Attributes:  attrs,
+	})
+	span.AddEvent(joinEventName, trace.WithAttributes(attrs...))
+}
+
+func formatKeys[K comparable](keys []K) []string {
+	formatted := make([]string, len(keys))
+	for i, k := range keys {
+		formatted[i] = fmt.Sprint(k)
+	}
+	return formatted
+}
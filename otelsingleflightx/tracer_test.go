@@ -0,0 +1,117 @@
+package otelsingleflightx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samber/go-singleflightx"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestTracerProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+func spansByName(spans tracetest.SpanStubs) map[string]tracetest.SpanStub {
+	byName := make(map[string]tracetest.SpanStub, len(spans))
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	return byName
+}
+
+func TestTracerLinksJoiners(t *testing.T) {
+	is := assert.New(t)
+
+	provider, exporter := newTestTracerProvider()
+	tracer := provider.Tracer("test")
+
+	g, err := singleflightx.NewGroup[string, int](
+		singleflightx.WithTracer[string](NewTracer[string](WithTracerProvider(provider))),
+	)
+	is.NoError(err)
+
+	leaderCtx, leaderSpan := tracer.Start(context.Background(), "leader")
+	joinerCtx, joinerSpan := tracer.Start(context.Background(), "joiner")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		results := g.DoXContext(leaderCtx, []string{"a", "b"}, func(ctx context.Context, keys []string) (map[string]int, error) {
+			close(started)
+			<-release
+			return map[string]int{"a": 1, "b": 2}, nil
+		})
+		is.Equal(1, results["a"].Value.Value)
+	}()
+	<-started
+
+	joinerDone := make(chan struct{})
+	go func() {
+		defer close(joinerDone)
+		v, err, shared := g.DoContext(joinerCtx, "b", func(ctx context.Context) (int, error) {
+			return 0, nil
+		})
+		is.NoError(err)
+		is.Equal(2, v)
+		is.True(shared)
+	}()
+	is.Eventually(func() bool {
+		return len(joinerSpan.(sdktrace.ReadOnlySpan).Links()) == 1
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	<-leaderDone
+	<-joinerDone
+	leaderSpan.End()
+	joinerSpan.End()
+
+	spans := spansByName(exporter.GetSpans())
+	is.Len(spans, 3)
+
+	execution := spans[DefaultSpanName]
+	is.Equal(spans["leader"].SpanContext.SpanID(), execution.Parent.SpanID())
+	is.Equal(codes.Unset, execution.Status.Code)
+	is.Contains(execution.Attributes, keysAttribute.StringSlice([]string{"a", "b"}))
+	is.Contains(execution.Attributes, keysCountAttribute.Int(2))
+
+	joiner := spans["joiner"]
+	is.Len(joiner.Links, 1)
+	is.Equal(execution.SpanContext, joiner.Links[0].SpanContext)
+	is.Contains(joiner.Links[0].Attributes, keyAttribute.String("b"))
+	is.Len(joiner.Events, 1)
+	is.Equal(joinEventName, joiner.Events[0].Name)
+
+	is.Empty(spans["leader"].Links)
+}
+
+func TestTracerRecordsErrors(t *testing.T) {
+	is := assert.New(t)
+
+	provider, exporter := newTestTracerProvider()
+	g := singleflightx.Group[string, int]{
+		Tracer: NewTracer[string](WithTracerProvider(provider), WithSpanName("load")),
+	}
+
+	someErr := errors.New("error")
+	_, err, _ := g.Do("key", func() (int, error) {
+		return 0, someErr
+	})
+	is.Equal(someErr, err)
+
+	spans := exporter.GetSpans()
+	is.Len(spans, 1)
+	is.Equal("load", spans[0].Name)
+	is.Equal(codes.Error, spans[0].Status.Code)
+	is.Equal("error", spans[0].Status.Description)
+	is.Len(spans[0].Events, 1)
+	is.Equal("exception", spans[0].Events[0].Name)
+}
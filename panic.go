@@ -0,0 +1,18 @@
+package singleflightx
+
+// PanicPolicy defines how the callers of a key are handled when the
+// function in charge of the key panics.
+type PanicPolicy int
+
+const (
+	// PanicCrash re-panics on the goroutine of every caller of the key. If
+	// some callers wait on a channel, or if every caller already gave up
+	// waiting, the panic is raised on a new goroutine so that it cannot be
+	// recovered, and crashes the process.
+	PanicCrash PanicPolicy = iota
+	// PanicRepanic re-panics on the goroutine of every caller of the key.
+	// Callers waiting on a channel receive the panic as an error instead.
+	PanicRepanic
+	// PanicAsError reports the panic as an error to every caller of the key.
+	PanicAsError
+)
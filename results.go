@@ -0,0 +1,72 @@
+package singleflightx
+
+import (
+	"context"
+	"fmt"
+)
+
+// keyErrors is the error of an execution whose keys may fail
+// independently. errs holds the errors of the keys that have their own
+// result but no value, with a nil error if they are absent. err is the
+// error of the whole execution, reported to the other keys without value.
+type keyErrors[K comparable] struct {
+	errs map[K]error
+	err  error
+}
+
+// Error implements error interface.
+func (e keyErrors[K]) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("singleflightx: %d keys failed", len(e.errs))
+}
+
+// Unwrap returns the error of the whole execution, if any.
+func (e keyErrors[K]) Unwrap() error {
+	return e.err
+}
+
+// keyError returns the error of key, that has a value if valid.
+func (e keyErrors[K]) keyError(key K, valid bool) error {
+	if err, ok := e.errs[key]; ok {
+		return err
+	}
+	if valid {
+		return nil
+	}
+	return e.err
+}
+
+// perKeyResults adapts a function returning a result per key. A key whose
+// result has an error is reported with that error, and a key without
+// result is reported with err.
+func perKeyResults[K comparable, V any](fn func([]K) (map[K]Result[V], error)) func(context.Context, []K) (map[K]V, error) {
+	return func(_ context.Context, keys []K) (map[K]V, error) {
+		results, err := fn(keys)
+
+		values := make(map[K]V, len(results))
+		errs := make(map[K]error, len(results))
+		failed := err != nil
+		for _, k := range keys {
+			r, ok := results[k]
+			if !ok {
+				continue
+			}
+
+			if r.Err != nil {
+				errs[k] = r.Err
+				failed = true
+			} else if r.Value.Valid {
+				values[k] = r.Value.Value
+			} else {
+				errs[k] = nil
+			}
+		}
+
+		if failed {
+			return values, keyErrors[K]{errs: errs, err: err}
+		}
+		return values, nil
+	}
+}
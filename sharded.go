@@ -1,11 +1,41 @@
 package singleflightx
 
-func NewShardedGroup[K comparable, V any](count uint, hasher Hasher[K]) *ShardedGroup[K, V] {
-	shards := make([]Group[K, V], count)
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// NewShardedGroup returns a ShardedGroup configured with opts. WithShards
+// and WithHasher are required. Other options apply to every shard. An error
+// is returned if the configuration is invalid.
+func NewShardedGroup[K comparable, V any](opts ...Option) (*ShardedGroup[K, V], error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.shards == 0 {
+		return nil, errors.New("singleflightx: WithShards is required")
+	}
+	if cfg.hasher == nil {
+		return nil, errors.New("singleflightx: WithHasher is required")
+	}
+	if cfg.maxWait != 0 {
+		return nil, errors.New("singleflightx: WithBatchWindow is only supported by NewBatcher")
+	}
+	hasher, ok := cfg.hasher.(Hasher[K])
+	if !ok {
+		var k K
+		return nil, fmt.Errorf("singleflightx: the hasher does not accept keys of type %T", k)
+	}
+
+	shards := make([]Group[K, V], cfg.shards)
 	for i := range shards {
-		shards[i] = Group[K, V]{}
+		if err := configure(&shards[i], cfg); err != nil {
+			return nil, err
+		}
 	}
-	return &ShardedGroup[K, V]{count: count, shards: shards, hasher: hasher}
+	return &ShardedGroup[K, V]{count: cfg.shards, shards: shards, hasher: hasher}, nil
 }
 
 // ShardedGroup is a duplicate of singleflight.Group, but with the ability to shard the map of calls.
@@ -27,6 +57,14 @@ func (sg *ShardedGroup[K, V]) Do(key K, fn func() (V, error)) (v V, err error, s
 	return sg.shards[i].Do(key, fn)
 }
 
+// DoContext is like Do but gives up waiting as soon as ctx is done, in
+// which case ctx.Err() is returned. The in-flight call is not interrupted
+// and keeps running for the other callers of the same key.
+func (sg *ShardedGroup[K, V]) DoContext(ctx context.Context, key K, fn func(context.Context) (V, error)) (v V, err error, shared bool) {
+	i := sg.hasher.computeHash(key, sg.count)
+	return sg.shards[i].DoContext(ctx, key, fn)
+}
+
 // DoChan is like Do but returns a channel that will receive the
 // results when they are ready.
 //
@@ -41,15 +79,64 @@ func (sg *ShardedGroup[K, V]) DoChan(key K, fn func() (V, error)) <-chan Result[
 // time. If a duplicate comes in, the duplicate caller waits for the
 // original to complete and receives the same results.
 // The return value shared indicates whether v was given to multiple callers.
-func (sg *ShardedGroup[K, V]) DoX(keys []K, fn func([]K) (map[K]V, error)) (results map[K]Result[V]) {
-	ch := sg.DoChanX(keys, fn)
+// If the keys match different shards, fn is called once per shard,
+// concurrently.
+func (sg *ShardedGroup[K, V]) DoX(keys []K, fn func([]K) (map[K]V, error), opts ...CallOption) (results map[K]Result[V]) {
+	return sg.doXContext(context.Background(), keys, ignoreContext(fn), funcID(fn), opts)
+}
+
+// DoXSlice is like DoX but returns the results in the order of keys. See
+// Group.DoXSlice. If the keys match different shards, fn is called once per
+// shard, concurrently.
+func (sg *ShardedGroup[K, V]) DoXSlice(keys []K, fn func([]K) (map[K]V, error), opts ...CallOption) []Result[V] {
+	return orderResults(keys, sg.doXContext(context.Background(), keys, ignoreContext(fn), funcID(fn), opts))
+}
+
+// DoXResults is like DoX but fn returns a result per key, so that keys
+// fail independently. See Group.DoXResults. If the keys match different
+// shards, fn is called once per shard, concurrently.
+func (sg *ShardedGroup[K, V]) DoXResults(keys []K, fn func([]K) (map[K]Result[V], error), opts ...CallOption) (results map[K]Result[V]) {
+	return sg.doXContext(context.Background(), keys, perKeyResults(fn), funcID(fn), opts)
+}
+
+// DoXContext is like DoX but gives up waiting as soon as ctx is done. In
+// that case, the keys that are not resolved yet are reported with ctx.Err()
+// in the results map. If the keys match different shards, fn is called
+// once per shard, concurrently.
+func (sg *ShardedGroup[K, V]) DoXContext(ctx context.Context, keys []K, fn func(context.Context, []K) (map[K]V, error), opts ...CallOption) (results map[K]Result[V]) {
+	return sg.doXContext(ctx, keys, fn, funcID(fn), opts)
+}
+
+// doXContext handles DoX, DoXResults and DoXContext. id identifies the
+// function given by the caller, see funcID.
+func (sg *ShardedGroup[K, V]) doXContext(ctx context.Context, keys []K, fn func(context.Context, []K) (map[K]V, error), id uintptr, opts []CallOption) (results map[K]Result[V]) {
+	co, err := newCallOptions[K, V](opts)
+	if err != nil {
+		return errorResults[K, V](keys, err)
+	}
+
+	keysByShard := partitionBy(keys, func(key K) uint {
+		return sg.hasher.computeHash(key, sg.count)
+	})
+
+	calls := make([]map[K]*call[K, V], 0, len(keysByShard))
+	shards := make([]uint, 0, len(keysByShard))
+	for i, keys := range keysByShard {
+		shardCalls, toCall := sg.shards[i].registerX(ctx, keys, nil, nil, sg.shards[i].newCandidate(ctx, fn))
+		sg.shards[i].startCallX(ctx, shardCalls, toCall, fn, id)
+
+		calls = append(calls, shardCalls)
+		shards = append(shards, i)
+	}
 
 	results = make(map[K]Result[V], len(keys))
-	for k, c := range ch {
-		results[k] = <-c
+	for j, i := range shards {
+		for k, r := range sg.shards[i].waitContextX(ctx, calls[j]) {
+			results[k] = r
+		}
 	}
 
-	return results
+	return sg.completeX(ctx, results, co)
 }
 
 // DoChanX is like Do but returns a channel that will receive the
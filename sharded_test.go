@@ -0,0 +1,204 @@
+package singleflightx
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestShardedGroup(t *testing.T, opts ...Option) *ShardedGroup[int, int] {
+	opts = append([]Option{
+		WithShards(4),
+		WithHasher(Hasher[int](func(key int) uint64 {
+			return uint64(key)
+		})),
+	}, opts...)
+
+	sg, err := NewShardedGroup[int, int](opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sg
+}
+
+func TestNewShardedGroup(t *testing.T) {
+	is := assert.New(t)
+	hasher := Hasher[int](func(key int) uint64 {
+		return uint64(key)
+	})
+
+	sg, err := NewShardedGroup[int, int](WithShards(4), WithHasher(hasher), WithPanicPolicy(PanicAsError))
+	is.NoError(err)
+	is.Len(sg.shards, 4)
+	for i := range sg.shards {
+		is.Equal(PanicAsError, sg.shards[i].PanicPolicy)
+	}
+
+	_, err = NewShardedGroup[int, int](WithHasher(hasher))
+	is.EqualError(err, "singleflightx: WithShards is required")
+	_, err = NewShardedGroup[int, int](WithShards(0), WithHasher(hasher))
+	is.EqualError(err, "singleflightx: the number of shards must be greater than zero")
+	_, err = NewShardedGroup[int, int](WithShards(4))
+	is.EqualError(err, "singleflightx: WithHasher is required")
+	_, err = NewShardedGroup[int, int](WithShards(4), WithHasher[int](nil))
+	is.EqualError(err, "singleflightx: nil hasher")
+	_, err = NewShardedGroup[int, int](WithShards(4), WithHasher(Hasher[string](func(key string) uint64 {
+		return 0
+	})))
+	is.EqualError(err, "singleflightx: the hasher does not accept keys of type int")
+}
+
+func TestShardedGroupDoX(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t)
+
+	v := sg.DoX([]int{1, 2, 3}, func(keys []int) (map[int]int, error) {
+		is.Len(keys, 1)
+		return map[int]int{keys[0]: keys[0] * 2}, nil
+	})
+	is.Len(v, 3)
+	is.Equal(2, v[1].Value.Value)
+	is.Equal(4, v[2].Value.Value)
+	is.Equal(6, v[3].Value.Value)
+
+	v = sg.DoXContext(context.Background(), []int{1, 5}, func(ctx context.Context, keys []int) (map[int]int, error) {
+		is.Len(keys, 2)
+		return map[int]int{1: 1}, nil
+	})
+	is.Len(v, 2)
+	is.True(v[1].Value.Valid)
+	is.False(v[5].Value.Valid)
+}
+
+func TestShardedGroupMaxConcurrency(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t, WithMaxConcurrency(1))
+
+	for i := range sg.shards {
+		is.Equal(1, sg.shards[i].MaxConcurrency)
+		is.Equal(sg.shards[0].sem, sg.shards[i].sem)
+	}
+
+	var running, maxRunning int32
+	v := sg.DoX([]int{1, 2, 3, 4}, func(keys []int) (map[int]int, error) {
+		if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return map[int]int{keys[0]: keys[0]}, nil
+	})
+	is.Len(v, 4)
+	is.Equal(int32(1), atomic.LoadInt32(&maxRunning))
+}
+
+func TestShardedGroupDoXResults(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t)
+	someErr := errors.New("error")
+
+	v := sg.DoXResults([]int{1, 2, 3}, func(keys []int) (map[int]Result[int], error) {
+		is.Len(keys, 1)
+		if keys[0] == 2 {
+			return map[int]Result[int]{2: {Err: someErr}}, nil
+		}
+		return map[int]Result[int]{keys[0]: {Value: NullValue[int]{keys[0] * 2, true}}}, nil
+	})
+	is.Len(v, 3)
+	is.Equal(2, v[1].Value.Value)
+	is.Equal(someErr, v[2].Err)
+	is.Equal(6, v[3].Value.Value)
+}
+
+func TestShardedGroupDoXResultsFailBisect(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t, WithFailurePolicy(FailBisect))
+	someErr := errors.New("error")
+
+	// Keys 1, 5 and 9 share the same shard.
+	v := sg.DoXResults([]int{1, 5, 9}, func(keys []int) (map[int]Result[int], error) {
+		results := make(map[int]Result[int], len(keys))
+		for _, k := range keys {
+			if k == 5 {
+				return nil, someErr
+			}
+			results[k] = Result[int]{Value: NullValue[int]{k * 2, true}}
+		}
+		return results, nil
+	})
+	is.Len(v, 3)
+	is.Equal(2, v[1].Value.Value)
+	is.Equal(someErr, v[5].Err)
+	is.Equal(18, v[9].Value.Value)
+}
+
+func TestShardedGroupFallback(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t)
+	var fallbackCalls int32
+
+	v := sg.DoX([]int{1, 2, 3}, func(keys []int) (map[int]int, error) {
+		if keys[0] == 2 {
+			return nil, nil
+		}
+		return map[int]int{keys[0]: keys[0] * 2}, nil
+	}, WithFallback(func(key int) (int, error) {
+		atomic.AddInt32(&fallbackCalls, 1)
+		return key * 10, nil
+	}))
+	is.Len(v, 3)
+	is.Equal(2, v[1].Value.Value)
+	is.Equal(20, v[2].Value.Value)
+	is.Equal(6, v[3].Value.Value)
+	is.Equal(int32(1), atomic.LoadInt32(&fallbackCalls))
+
+	v = sg.DoXContext(context.Background(), []int{1, 5}, func(ctx context.Context, keys []int) (map[int]int, error) {
+		return nil, nil
+	}, WithFallback(func(key int) (int, error) {
+		return key * 10, nil
+	}))
+	is.Equal(10, v[1].Value.Value)
+	is.Equal(50, v[5].Value.Value)
+}
+
+func TestShardedGroupAbsentPolicy(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t, WithAbsentPolicy(AbsentError))
+
+	v := sg.DoX([]int{1, 2}, func(keys []int) (map[int]int, error) {
+		return map[int]int{1: 1}, nil
+	})
+	is.Equal(1, v[1].Value.Value)
+	is.ErrorIs(v[2].Err, ErrNotFound)
+
+	v = sg.DoX([]int{1, 2}, func(keys []int) (map[int]int, error) {
+		return nil, nil
+	}, WithCallAbsentDefault(0))
+	is.True(v[1].Value.Valid)
+	is.True(v[2].Value.Valid)
+}
+
+func TestShardedGroupDoXSlice(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t)
+
+	v := sg.DoXSlice([]int{3, 1, 5, 2, 1}, func(keys []int) (map[int]int, error) {
+		values := map[int]int{}
+		for _, k := range keys {
+			if k != 2 {
+				values[k] = k * 2
+			}
+		}
+		return values, nil
+	})
+	is.Len(v, 5)
+	is.Equal(6, v[0].Value.Value)
+	is.Equal(2, v[1].Value.Value)
+	is.Equal(10, v[2].Value.Value)
+	is.False(v[3].Value.Valid)
+	is.Equal(2, v[4].Value.Value)
+}
@@ -2,30 +2,48 @@ package singleflightx
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
 	"runtime/debug"
 	"sync"
+	"time"
 )
 
-// errGoexit indicates the runtime.Goexit was called in
-// the user given function.
-var errGoexit = errors.New("runtime.Goexit was called")
+// ErrGoexit indicates the runtime.Goexit was called in
+// the user given function. It is only reported to the callers of a
+// detached group, on the channel of DoXStreamChan, by the iterator of
+// DoXSeq and by the futures of Go and GoX. On other groups, the callers are
+// terminated as well, and the channels of DoChan and DoChanX receive
+// nothing.
+var ErrGoexit = errors.New("runtime.Goexit was called")
 
-// A panicError is an arbitrary value recovered from a panic
+// A PanicError is an arbitrary value recovered from a panic
 // with the stack trace during the execution of given function.
-type panicError struct {
+type PanicError struct {
 	value interface{}
 	stack []byte
 }
 
 // Error implements error interface.
-func (p *panicError) Error() string {
+func (p *PanicError) Error() string {
 	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
 }
 
-func (p *panicError) Unwrap() error {
+// Value returns the value the given function panicked with.
+func (p *PanicError) Value() interface{} {
+	return p.value
+}
+
+// Stack returns the stack trace of the goroutine that panicked.
+func (p *PanicError) Stack() []byte {
+	return p.stack
+}
+
+// Unwrap returns the value the given function panicked with, if it
+// is an error.
+func (p *PanicError) Unwrap() error {
 	err, ok := p.value.(error)
 	if !ok {
 		return nil
@@ -43,31 +61,162 @@ func newPanicError(v interface{}) error {
 	if line := bytes.IndexByte(stack[:], '\n'); line >= 0 {
 		stack = stack[line+1:]
 	}
-	return &panicError{value: v, stack: stack}
+	return &PanicError{value: v, stack: stack}
 }
 
 // call is an in-flight or completed singleflight.Do call
-type call[V any] struct {
-	wg sync.WaitGroup
+type call[K comparable, V any] struct {
+	// done is closed once the call completes.
+	done chan struct{}
 
-	// These fields are written once before the WaitGroup is done
-	// and are only read after the WaitGroup is done.
+	// These fields are written once before done is closed
+	// and are only read after done is closed.
 	value  V
 	absent bool
 	err    error
 
 	// These fields are read and written with the singleflight
-	// mutex held before the WaitGroup is done, and are read but
-	// not written after the WaitGroup is done.
-	dups  int
-	chans []chan<- Result[V]
+	// mutex held before done is closed, and are read but
+	// not written after done is closed.
+	dups    int
+	chans   []chan<- Result[V]
+	streams []*resultStream[K, V]
+
+	// resolved reports whether the call was completed early, by the emit
+	// function of DoXStream. It is read and written with the singleflight
+	// mutex held, and is read without it once the stream is closed.
+	resolved bool
+
+	// waiters is the number of callers still interested in the result,
+	// among which unbounded have no deadline. deadlines holds the deadlines
+	// of the other ones. exec is the execution in charge of the call, if it
+	// can be canceled. They are read and written with the singleflight
+	// mutex held.
+	waiters   int
+	unbounded int
+	deadlines []time.Time
+	exec      *execution[K, V]
+
+	// candidates may be elected to run their own function when the
+	// execution of the call exits via runtime.Goexit. It is read and
+	// written with the singleflight mutex held.
+	candidates []*candidate[K, V]
+
+	// traceCtx is the context returned by the tracer for the execution in
+	// charge of the call, once started. traceJoins holds the contexts of the
+	// callers that joined before. They are read and written with the
+	// singleflight mutex held.
+	traceCtx   context.Context
+	traceJoins []context.Context
+}
+
+// notify sends the result r of key on the channels and streams of c.
+func (c *call[K, V]) notify(key K, r Result[V]) {
+	for _, ch := range c.chans {
+		ch <- r
+	}
+	for _, s := range c.streams {
+		s.send(key, r)
+	}
+}
+
+// notifyStreams is like notify, for the streams of c only.
+func (c *call[K, V]) notifyStreams(key K, r Result[V]) {
+	for _, s := range c.streams {
+		s.send(key, r)
+	}
+}
+
+// notified reports whether the result of c is sent on channels or streams.
+func (c *call[K, V]) notified() bool {
+	return len(c.chans) > 0 || len(c.streams) > 0
+}
+
+// newCall returns a call started by a caller waiting with ctx.
+func newCall[K comparable, V any](ctx context.Context) *call[K, V] {
+	c := &call[K, V]{done: make(chan struct{})}
+	c.join(ctx)
+	return c
 }
 
 // Group represents a class of work and forms a namespace in
 // which units of work can be executed with duplicate suppression.
 type Group[K comparable, V any] struct {
-	mu sync.Mutex     // protects m
-	m  map[K]*call[V] // lazily initialized
+	// GoexitPolicy defines how callers are handled when the given
+	// function exits via runtime.Goexit. Defaults to GoexitPropagate.
+	GoexitPolicy GoexitPolicy
+
+	// PanicPolicy defines how callers are handled when the given function
+	// panics. Defaults to PanicCrash.
+	PanicPolicy PanicPolicy
+
+	// FailurePolicy defines how the keys of a batch are handled when the
+	// function given to DoX, DoXContext, DoXResults or DoChanX fails.
+	// Defaults to FailBatch.
+	FailurePolicy FailurePolicy
+
+	// Observer, if not nil, is notified of the lifecycle of every call.
+	Observer Observer[K]
+
+	// Tracer, if not nil, is notified of every execution and of the callers
+	// joining it, with their context.
+	Tracer Tracer[K]
+
+	// MaxBatchSize, if greater than zero, is the maximum number of keys
+	// passed to a single invocation of the functions given to DoX,
+	// DoXContext and DoChanX. The keys to be called are split into batches
+	// of at most MaxBatchSize keys, executed concurrently.
+	MaxBatchSize int
+
+	// MaxConcurrency, if greater than zero, is the maximum number of
+	// functions given to DoX, DoXContext and DoChanX executing at once,
+	// across every caller of the group, and across every shard of a
+	// ShardedGroup. Other executions wait for a slot, or give up when their
+	// context is done. A function must not wait for another call of the same
+	// group, as it holds a slot.
+	MaxConcurrency int
+
+	// MergeDelay, if greater than zero, is how long the keys that are not
+	// in-flight wait before being executed, when given to DoX, DoXContext
+	// and DoChanX. The keys of every caller arriving during that delay with
+	// the same function are merged and executed through a single call of
+	// it, then the results are routed to each caller. The functions are
+	// always run on a goroutine owned by the group.
+	//
+	// Functions are the same if they are the same func value, such as a
+	// declared function or a closure shared by the callers. Closures
+	// created by each caller, or method values, are distinct values and
+	// are never merged, even when they come from the same function literal
+	// or method. The context passed to the function only carries the values
+	// of the context of the first caller, not the ones of the later callers.
+	MergeDelay time.Duration
+
+	// AbsentPolicy defines how the keys absent from the results of the
+	// function given to DoX, DoXContext, DoXResults or DoChanX are
+	// reported, unless overridden by a call option. Absent keys are
+	// resolved by the fallback of the call, if any, beforehand. Defaults to
+	// AbsentNull.
+	AbsentPolicy AbsentPolicy
+
+	// AbsentDefault is the value reported for absent keys with the
+	// AbsentDefault policy.
+	AbsentDefault V
+
+	// Detached makes the given functions always run on a goroutine owned by
+	// the group, with a context that is not derived from the one of the
+	// first caller. The first caller then waits like any other caller, and
+	// a runtime.Goexit in the given function is reported to every caller
+	// as an error instead of terminating their goroutine.
+	//
+	// Detached, GoexitPolicy, PanicPolicy, FailurePolicy, Observer, Tracer,
+	// MaxBatchSize, MaxConcurrency, MergeDelay, AbsentPolicy and
+	// AbsentDefault must not be changed once the group is in use.
+	Detached bool
+
+	mu    sync.Mutex        // protects m and sem
+	m     map[K]*call[K, V] // lazily initialized
+	sem   chan struct{}     // lazily initialized, see MaxConcurrency
+	merge window[K, V]      // see MergeDelay
 }
 
 // NullValue represents a V that may be null.
@@ -92,91 +241,203 @@ type Result[V any] struct {
 func (g *Group[K, V]) Do(key K, fn func() (V, error)) (v V, err error, shared bool) {
 	g.mu.Lock()
 	if g.m == nil {
-		g.m = make(map[K]*call[V])
+		g.m = make(map[K]*call[K, V])
+	}
+	ctxFn := func(context.Context) (V, error) {
+		return fn()
 	}
 	if c, ok := g.m[key]; ok {
 		c.dups++
+		c.join(context.Background())
+		c.addCandidate(g.newCandidate(context.Background(), singleKey[K](ctxFn)))
+		traceCtx := g.addTraceJoin(context.Background(), c)
 		g.mu.Unlock()
-		c.wg.Wait()
-
-		if e, ok := c.err.(*panicError); ok {
-			panic(e)
-		} else if c.err == errGoexit {
-			runtime.Goexit()
-		}
-		return c.value, c.err, true
+		g.observeJoin(key)
+		g.traceJoin(context.Background(), traceCtx, key)
+		return g.waitContext(context.Background(), c, true)
 	}
-	c := new(call[V])
-	c.wg.Add(1)
+	c := newCall[K, V](context.Background())
 	g.m[key] = c
 	g.mu.Unlock()
 
-	g.doCall(c, key, fn)
+	if g.Detached {
+		go g.doCall(context.Background(), c, key, ctxFn, false)
+		return g.waitContext(context.Background(), c, false)
+	}
+
+	g.doCall(context.Background(), c, key, ctxFn, true)
 	return c.value, c.err, c.dups > 0
 }
 
+// DoContext is like Do but gives up waiting as soon as ctx is done, in
+// which case ctx.Err() is returned. The in-flight call is not interrupted
+// and keeps running for the other callers of the same key.
+//
+// fn is executed on its own goroutine. The context passed to fn carries
+// the values of ctx, unless the group is detached, and the latest deadline
+// among the callers of the key. It is canceled once every caller of the key
+// has given up waiting.
+func (g *Group[K, V]) DoContext(ctx context.Context, key K, fn func(context.Context) (V, error)) (v V, err error, shared bool) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[K]*call[K, V])
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.join(ctx)
+		c.addCandidate(g.newCandidate(ctx, singleKey[K](fn)))
+		traceCtx := g.addTraceJoin(ctx, c)
+		g.mu.Unlock()
+		g.observeJoin(key)
+		g.traceJoin(ctx, traceCtx, key)
+		return g.waitContext(ctx, c, true)
+	}
+	c := newCall[K, V](ctx)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(ctx, c, key, func(ctx context.Context) (V, error) {
+		fnCtx, e := g.startExecution(ctx, map[K]*call[K, V]{key: c}, []K{key})
+		defer e.done()
+		return fn(fnCtx)
+	}, false)
+
+	return g.waitContext(ctx, c, false)
+}
+
+// waitContext waits for c to complete or for ctx to be done, whichever
+// happens first.
+func (g *Group[K, V]) waitContext(ctx context.Context, c *call[K, V], joined bool) (v V, err error, shared bool) {
+	if !waitDone(ctx, c.done) {
+		g.leave(ctx, c)
+		return v, ctx.Err(), false
+	}
+
+	if e, ok := c.err.(*PanicError); ok && g.PanicPolicy != PanicAsError {
+		panic(e)
+	} else if c.err == ErrGoexit && !g.Detached {
+		runtime.Goexit()
+	}
+	return c.value, c.err, joined || c.dups > 0
+}
+
 // DoChan is like Do but returns a channel that will receive the
 // results when they are ready.
 //
 // The returned channel will not be closed.
 func (g *Group[K, V]) DoChan(key K, fn func() (V, error)) <-chan Result[V] {
 	ch := make(chan Result[V], 1)
+	g.startCall(key, fn, ch)
+	return ch
+}
+
+// startCall joins the in-flight call of key, or starts one on a goroutine
+// owned by the group. The result is also sent on ch, if not nil.
+func (g *Group[K, V]) startCall(key K, fn func() (V, error), ch chan Result[V]) *call[K, V] {
 	g.mu.Lock()
 	if g.m == nil {
-		g.m = make(map[K]*call[V])
+		g.m = make(map[K]*call[K, V])
+	}
+	ctxFn := func(context.Context) (V, error) {
+		return fn()
 	}
 	if c, ok := g.m[key]; ok {
 		c.dups++
-		c.chans = append(c.chans, ch)
+		c.join(context.Background())
+		c.addCandidate(g.newCandidate(context.Background(), singleKey[K](ctxFn)))
+		if ch != nil {
+			c.chans = append(c.chans, ch)
+		}
+		traceCtx := g.addTraceJoin(context.Background(), c)
 		g.mu.Unlock()
-		return ch
+		g.observeJoin(key)
+		g.traceJoin(context.Background(), traceCtx, key)
+		return c
+	}
+	c := newCall[K, V](context.Background())
+	if ch != nil {
+		c.chans = []chan<- Result[V]{ch}
 	}
-	c := &call[V]{chans: []chan<- Result[V]{ch}}
-	c.wg.Add(1)
 	g.m[key] = c
 	g.mu.Unlock()
 
-	go g.doCall(c, key, fn)
+	go g.doCall(context.Background(), c, key, ctxFn, false)
 
-	return ch
+	return c
 }
 
-// doCall handles the single call for a key.
-func (g *Group[K, V]) doCall(c *call[V], key K, fn func() (V, error)) {
+// doCall handles the single call for a key, started by a caller waiting
+// with ctx. inline reports whether doCall runs on the goroutine of that
+// caller.
+func (g *Group[K, V]) doCall(ctx context.Context, c *call[K, V], key K, fn func(context.Context) (V, error), inline bool) {
 	normalReturn := false
 	recovered := false
+	keys := []K{key}
+	ctx, end := g.startTrace(ctx, map[K]*call[K, V]{key: c}, keys)
+	start := g.observeStart(keys)
 
 	// use double-defer to distinguish panic from runtime.Goexit,
 	// more details see https://golang.org/cl/134395
 	defer func() {
 		// the given function invoked runtime.Goexit
-		if !normalReturn && !recovered {
-			c.err = errGoexit
+		goexit := !normalReturn && !recovered
+		if goexit {
+			c.err = ErrGoexit
 		}
 
 		g.mu.Lock()
-		defer g.mu.Unlock()
-		c.wg.Done()
+
+		if goexit && g.GoexitPolicy == GoexitReelect {
+			if inline {
+				// The goroutine of the caller is terminating.
+				c.unjoin(context.Background())
+			}
+			if len(g.reelect(map[K]*call[K, V]{key: c}, keys)) == 0 {
+				dups := c.dups
+				g.mu.Unlock()
+				g.observeComplete(keys, ErrGoexit, start, dups)
+				g.endTrace(end, ErrGoexit)
+				return
+			}
+		}
+
 		if g.m[key] == c {
 			delete(g.m, key)
 		}
+		close(c.done)
 
-		if e, ok := c.err.(*panicError); ok {
+		e, panicked := c.err.(*PanicError)
+		if panicked && g.PanicPolicy == PanicCrash {
+			// Handled below, once the mutex is released.
+		} else if c.err == ErrGoexit && !g.Detached {
+			// Already in the process of goexit, no need to call again. Streams
+			// are closed after their last key, so they receive ErrGoexit.
+			c.notifyStreams(key, Result[V]{Err: c.err})
+		} else {
+			// Normal return, or a panic reported to the channels as an error
+			c.notify(key, Result[V]{NullValue[V]{c.value, !c.absent}, c.err, c.dups > 0})
+		}
+
+		// Nobody is left to re-panic if every caller gave up waiting.
+		dups, crash := c.dups, c.notified() || c.waiters == 0
+		g.mu.Unlock()
+
+		if panicked {
+			g.observePanic(keys, e)
+		}
+		g.observeComplete(keys, c.err, start, dups)
+		g.endTrace(end, c.err)
+
+		if panicked && g.PanicPolicy != PanicAsError {
 			// In order to prevent the waiting channels from being blocked forever,
 			// needs to ensure that this panic cannot be recovered.
-			if len(c.chans) > 0 {
+			if crash && g.PanicPolicy == PanicCrash {
 				go panic(e)
 				select {} // Keep this goroutine around so that it will appear in the crash dump.
-			} else {
+			} else if inline {
 				panic(e)
 			}
-		} else if c.err == errGoexit {
-			// Already in the process of goexit, no need to call again
-		} else {
-			// Normal return
-			for _, ch := range c.chans {
-				ch <- Result[V]{NullValue[V]{c.value, !c.absent}, c.err, c.dups > 0}
-			}
+			// Otherwise, waiters re-panic on their own goroutine.
 		}
 	}()
 
@@ -196,7 +457,7 @@ func (g *Group[K, V]) doCall(c *call[V], key K, fn func() (V, error)) {
 			}
 		}()
 
-		c.value, c.err = fn()
+		c.value, c.err = fn(ctx)
 		normalReturn = true
 	}()
 
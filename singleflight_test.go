@@ -2,6 +2,7 @@ package singleflightx
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -30,15 +31,20 @@ func TestPanicErrorUnwrap(t *testing.T) {
 		wrappedErrorType bool
 	}{
 		{
-			name:             "panicError wraps non-error type",
-			panicValue:       &panicError{value: "string value"},
+			name:             "PanicError wraps non-error type",
+			panicValue:       &PanicError{value: "string value"},
 			wrappedErrorType: false,
 		},
 		{
-			name:             "panicError wraps error type",
-			panicValue:       &panicError{value: new(errValue)},
+			name:             "PanicError wraps error type",
+			panicValue:       &PanicError{value: new(errValue)},
 			wrappedErrorType: false,
 		},
+		{
+			name:             "error type",
+			panicValue:       new(errValue),
+			wrappedErrorType: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -337,6 +343,49 @@ func TestPanicDoChan(t *testing.T) {
 	}
 }
 
+func TestPanicDoContextAbandoned(t *testing.T) {
+	if os.Getenv("TEST_PANIC_DOCHAN") != "" {
+		g := new(Group[string, int])
+		release := make(chan struct{})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err, _ := g.DoContext(ctx, "", func(context.Context) (int, error) {
+			<-release
+			panic("Panicking in abandoned DoContext")
+		})
+		if err != context.Canceled {
+			t.Fatalf("DoContext unexpectedly returned %v", err)
+		}
+		close(release)
+		time.Sleep(time.Second)
+		t.Fatalf("Abandoned panic unexpectedly dropped")
+	}
+
+	t.Parallel()
+
+	cmd := exec.Command(executable(t), "-test.run="+t.Name(), "-test.v")
+	cmd.Env = append(os.Environ(), "TEST_PANIC_DOCHAN=1")
+	out := new(bytes.Buffer)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := cmd.Wait()
+	t.Logf("%s:\n%s", strings.Join(cmd.Args, " "), out)
+	if err == nil {
+		t.Errorf("Test subprocess passed; want a crash due to panic in DoContext")
+	}
+	if bytes.Contains(out.Bytes(), []byte("unexpectedly")) {
+		t.Errorf("Test subprocess failed with an unexpected failure mode.")
+	}
+	if !bytes.Contains(out.Bytes(), []byte("Panicking in abandoned DoContext")) {
+		t.Errorf("Test subprocess failed, but the crash isn't caused by panicking in DoContext")
+	}
+}
+
 func TestPanicDoSharedByDoChan(t *testing.T) {
 	if os.Getenv("TEST_PANIC_DOCHAN") != "" {
 		blocked := make(chan struct{})
@@ -386,3 +435,374 @@ func TestPanicDoSharedByDoChan(t *testing.T) {
 		t.Errorf("Test subprocess failed, but the crash isn't caused by panicking in Do")
 	}
 }
+
+func TestDoContext(t *testing.T) {
+	var g Group[string, string]
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "bar")
+
+	v, err, _ := g.DoContext(ctx, "key", func(ctx context.Context) (string, error) {
+		return ctx.Value(ctxKey{}).(string), nil
+	})
+	if got, want := fmt.Sprintf("%v (%T)", v, v), "bar (string)"; got != want {
+		t.Errorf("DoContext = %v; want %v", got, want)
+	}
+	if err != nil {
+		t.Errorf("DoContext error = %v", err)
+	}
+}
+
+func TestDoContextCancel(t *testing.T) {
+	var g Group[string, string]
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	fn := func(ctx context.Context) (string, error) {
+		close(started)
+		<-unblock
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "bar", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() {
+		_, err, _ := g.DoContext(ctx, "key", fn)
+		leaderDone <- err
+	}()
+	<-started
+
+	joinerDone := make(chan string, 1)
+	go func() {
+		v, _, _ := g.DoContext(context.Background(), "key", fn)
+		joinerDone <- v
+	}()
+	for dups := 0; dups == 0; {
+		time.Sleep(time.Millisecond)
+		g.mu.Lock()
+		dups = g.m["key"].dups
+		g.mu.Unlock()
+	}
+
+	cancel()
+	select {
+	case err := <-leaderDone:
+		if err != context.Canceled {
+			t.Errorf("DoContext error = %v; want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("DoContext did not return after its context was canceled")
+	}
+
+	close(unblock)
+	if v := <-joinerDone; v != "bar" {
+		t.Errorf("DoContext = %v; want %v", v, "bar")
+	}
+}
+
+func TestDoContextAbandon(t *testing.T) {
+	var g Group[string, string]
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	fn := func(ctx context.Context) (string, error) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return "", ctx.Err()
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	done := make(chan struct{}, 2)
+	go func() {
+		g.DoContext(ctx1, "key", fn) //nolint:errcheck
+		done <- struct{}{}
+	}()
+	<-started
+	go func() {
+		g.DoContext(ctx2, "key", fn) //nolint:errcheck
+		done <- struct{}{}
+	}()
+	for dups := 0; dups == 0; {
+		time.Sleep(time.Millisecond)
+		g.mu.Lock()
+		dups = g.m["key"].dups
+		g.mu.Unlock()
+	}
+
+	cancel1()
+	<-done
+	select {
+	case <-canceled:
+		t.Fatalf("fn canceled while a caller is still waiting")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancel2()
+	<-done
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatalf("fn not canceled after every caller gave up")
+	}
+
+	// The abandoned call is forgotten.
+	v, err, _ := g.DoContext(context.Background(), "key", func(ctx context.Context) (string, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil {
+		t.Errorf("DoContext = %v, %v; want %v, nil", v, err, "bar")
+	}
+}
+
+func TestDoContextDeadline(t *testing.T) {
+	var g Group[string, time.Time]
+
+	short, cancelShort := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelShort()
+	long, cancelLong := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelLong()
+	shortDeadline, _ := short.Deadline()
+	longDeadline, _ := long.Deadline()
+
+	started := make(chan struct{})
+	joined := make(chan struct{})
+	fn := func(ctx context.Context) (time.Time, error) {
+		if d, _ := ctx.Deadline(); !d.Equal(shortDeadline) {
+			t.Errorf("initial deadline = %v; want %v", d, shortDeadline)
+		}
+		close(started)
+		<-joined
+
+		// The short caller gives up, but the call goes on for the long one.
+		time.Sleep(40 * time.Millisecond)
+		if err := ctx.Err(); err != nil {
+			return time.Time{}, err
+		}
+		d, _ := ctx.Deadline()
+		return d, nil
+	}
+
+	go func() {
+		_, err, _ := g.DoContext(short, "key", fn)
+		if err != context.DeadlineExceeded {
+			t.Errorf("DoContext error = %v; want %v", err, context.DeadlineExceeded)
+		}
+	}()
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d, err, _ := g.DoContext(long, "key", fn)
+		if err != nil {
+			t.Errorf("DoContext error = %v", err)
+		}
+		if !d.Equal(longDeadline) {
+			t.Errorf("deadline = %v; want %v", d, longDeadline)
+		}
+	}()
+	for dups := 0; dups == 0; {
+		time.Sleep(time.Millisecond)
+		g.mu.Lock()
+		dups = g.m["key"].dups
+		g.mu.Unlock()
+	}
+	close(joined)
+	<-done
+}
+
+func TestDetachedGoexitDo(t *testing.T) {
+	g := Group[string, int]{Detached: true}
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	fn := func() (int, error) {
+		close(started)
+		<-unblock
+		runtime.Goexit()
+		return 0, nil
+	}
+
+	const n = 5
+	errs := make(chan error, n)
+	go func() {
+		_, err, _ := g.Do("key", fn)
+		errs <- err
+	}()
+	<-started
+	for i := 1; i < n; i++ {
+		go func() {
+			_, err, _ := g.Do("key", fn)
+			errs <- err
+		}()
+	}
+	for dups := 0; dups < n-1; {
+		time.Sleep(time.Millisecond)
+		g.mu.Lock()
+		dups = g.m["key"].dups
+		g.mu.Unlock()
+	}
+	close(unblock)
+
+	for i := 0; i < n; i++ {
+		select {
+		case err := <-errs:
+			if err != ErrGoexit {
+				t.Errorf("Do error = %v; want %v", err, ErrGoexit)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Do hangs")
+		}
+	}
+}
+
+func TestDetachedDoContext(t *testing.T) {
+	g := Group[string, bool]{Detached: true}
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "bar")
+
+	v, err, _ := g.DoContext(ctx, "key", func(ctx context.Context) (bool, error) {
+		return ctx.Value(ctxKey{}) == nil, nil
+	})
+	if !v || err != nil {
+		t.Errorf("DoContext = %v, %v; want a context not derived from the caller's", v, err)
+	}
+}
+
+func TestGoexitReelectDo(t *testing.T) {
+	g := Group[string, int]{GoexitPolicy: GoexitReelect}
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		g.Do("key", func() (int, error) { //nolint:errcheck
+			close(started)
+			<-unblock
+			runtime.Goexit()
+			return 0, nil
+		})
+		t.Errorf("Do unexpectedly returned after runtime.Goexit")
+	}()
+	<-started
+
+	const n = 5
+	var calls int32
+	fn := func() (int, error) {
+		atomic.AddInt32(&calls, 1)
+		return 42, nil
+	}
+
+	type result struct {
+		v      int
+		err    error
+		shared bool
+	}
+	results := make(chan result, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			v, err, shared := g.Do("key", fn)
+			results <- result{v, err, shared}
+		}()
+	}
+	for dups := 0; dups < n; {
+		time.Sleep(time.Millisecond)
+		g.mu.Lock()
+		dups = g.m["key"].dups
+		g.mu.Unlock()
+	}
+	close(unblock)
+	<-leaderDone
+
+	for i := 0; i < n; i++ {
+		select {
+		case r := <-results:
+			if r.v != 42 || r.err != nil || !r.shared {
+				t.Errorf("Do = %v, %v, %v; want 42, nil, true", r.v, r.err, r.shared)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Do hangs")
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("number of calls = %d; want 1", got)
+	}
+}
+
+func TestPanicAsErrorDo(t *testing.T) {
+	g := Group[string, int]{PanicPolicy: PanicAsError}
+
+	_, err, _ := g.Do("key", func() (int, error) {
+		panic("Panicking in Do")
+	})
+	if _, ok := err.(*PanicError); !ok {
+		t.Errorf("Do error = %v; want a *PanicError", err)
+	}
+
+	res := <-g.DoChan("key", func() (int, error) {
+		panic("Panicking in DoChan")
+	})
+	if _, ok := res.Err.(*PanicError); !ok {
+		t.Errorf("DoChan error = %v; want a *PanicError", res.Err)
+	}
+}
+
+func TestPanicRepanicDoSharedByDoChan(t *testing.T) {
+	g := Group[string, int]{PanicPolicy: PanicRepanic}
+
+	blocked := make(chan struct{})
+	unblock := make(chan struct{})
+	recovered := make(chan interface{})
+	go func() {
+		defer func() {
+			recovered <- recover()
+		}()
+		g.Do("key", func() (int, error) { //nolint:errcheck
+			close(blocked)
+			<-unblock
+			panic("Panicking in Do")
+		})
+	}()
+
+	<-blocked
+	ch := g.DoChan("key", func() (int, error) {
+		panic("DoChan unexpectedly executed callback")
+	})
+	close(unblock)
+
+	if r := <-recovered; r == nil {
+		t.Errorf("Do did not panic")
+	}
+	res := <-ch
+	if _, ok := res.Err.(*PanicError); !ok {
+		t.Errorf("DoChan error = %v; want a *PanicError", res.Err)
+	}
+}
+
+func TestPanicErrorAccessors(t *testing.T) {
+	g := Group[string, int]{PanicPolicy: PanicAsError}
+	someErr := errors.New("Some error")
+
+	_, err, _ := g.Do("key", func() (int, error) {
+		panic(someErr)
+	})
+
+	var e *PanicError
+	if !errors.As(err, &e) {
+		t.Fatalf("Do error = %v; want a *PanicError", err)
+	}
+	if e.Value() != someErr {
+		t.Errorf("Value() = %v; want %v", e.Value(), someErr)
+	}
+	if !bytes.Contains(e.Stack(), []byte("TestPanicErrorAccessors")) {
+		t.Errorf("Stack() does not contain the panicking function:\n%s", e.Stack())
+	}
+	if !errors.Is(err, someErr) {
+		t.Errorf("errors.Is(%v, %v) = false; want true", err, someErr)
+	}
+}
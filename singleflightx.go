@@ -1,6 +1,9 @@
 package singleflightx
 
-import "runtime"
+import (
+	"context"
+	"runtime"
+)
 
 // DoX executes and returns the results of the given function, making
 // sure that only one execution is in-flight for a given key at a
@@ -9,46 +12,223 @@ import "runtime"
 // The return value shared indicates whether v was given to multiple callers.
 // Even if fn does not return V on some keys, the results map will contain
 // those keys with a `Valid` field set to false.
-func (g *Group[K, V]) DoX(keys []K, fn func([]K) (map[K]V, error)) (results map[K]Result[V]) {
-	results = make(map[K]Result[V], len(keys))
-	calls := make(map[K]*call[V], len(keys))
-	toCall := []K{}
+//
+// opts configure this call only. If they are invalid, every key is
+// reported with the configuration error.
+func (g *Group[K, V]) DoX(keys []K, fn func([]K) (map[K]V, error), opts ...CallOption) (results map[K]Result[V]) {
+	return g.doX(keys, ignoreContext(fn), funcID(fn), opts)
+}
+
+// DoXResults is like DoX but fn returns a result per key, so that keys
+// fail independently: every caller of a key receives exactly the error of
+// that key. The Shared field of the results returned by fn is ignored. A
+// key without result is reported with the error returned by fn, or with a
+// `Valid` field set to false if nil.
+func (g *Group[K, V]) DoXResults(keys []K, fn func([]K) (map[K]Result[V], error), opts ...CallOption) (results map[K]Result[V]) {
+	return g.doX(keys, perKeyResults(fn), funcID(fn), opts)
+}
+
+// doX handles DoX and DoXResults, for a function that ignores its context.
+// id identifies the function given by the caller, see funcID.
+func (g *Group[K, V]) doX(keys []K, ctxFn func(context.Context, []K) (map[K]V, error), id uintptr, opts []CallOption) (results map[K]Result[V]) {
+	co, err := newCallOptions[K, V](opts)
+	if err != nil {
+		return errorResults[K, V](keys, err)
+	}
+
+	calls := g.runX(keys, ctxFn, id)
+
+	return g.completeX(context.Background(), g.waitContextX(context.Background(), calls), co)
+}
+
+// DoXSlice is like DoX but returns the results in the order of keys. A key
+// given many times is answered at each of its positions.
+//
+// DoXSlice does not avoid the allocation of maps: the keys are registered,
+// and their results collected, in maps as for DoX, before being ordered.
+func (g *Group[K, V]) DoXSlice(keys []K, fn func([]K) (map[K]V, error), opts ...CallOption) []Result[V] {
+	return orderResults(keys, g.doX(keys, ignoreContext(fn), funcID(fn), opts))
+}
+
+// orderResults returns the results of keys in the order of keys.
+func orderResults[K comparable, V any](keys []K, results map[K]Result[V]) []Result[V] {
+	ordered := make([]Result[V], len(keys))
+	for i, k := range keys {
+		ordered[i] = results[k]
+	}
+	return ordered
+}
+
+// runX joins the in-flight calls of keys and handles the other ones, on
+// behalf of a caller that does not give up waiting, for a function that
+// ignores its context. id identifies the function given by the caller, see
+// funcID.
+func (g *Group[K, V]) runX(keys []K, ctxFn func(context.Context, []K) (map[K]V, error), id uintptr) (calls map[K]*call[K, V]) {
+	calls, toCall := g.registerX(context.Background(), keys, nil, nil, g.newCandidate(context.Background(), ctxFn))
+
+	if g.Detached || g.MergeDelay > 0 {
+		g.startCallX(context.Background(), calls, toCall, ctxFn, id)
+	} else {
+		g.doCallXBatches(context.Background(), calls, toCall, g.limit(g.bisect(ctxFn)), true)
+	}
+
+	return calls
+}
+
+// DoXContext is like DoX but gives up waiting as soon as ctx is done. In
+// that case, the keys that are not resolved yet are reported with ctx.Err()
+// in the results map. The in-flight calls are not interrupted and keep
+// running for the other callers of the same keys.
+//
+// fn is executed on its own goroutine. The context passed to fn carries
+// the values of ctx, unless the group is detached, and the latest deadline
+// among the callers of the keys passed to fn. It is canceled once every
+// caller of these keys has given up waiting.
+func (g *Group[K, V]) DoXContext(ctx context.Context, keys []K, fn func(context.Context, []K) (map[K]V, error), opts ...CallOption) (results map[K]Result[V]) {
+	co, err := newCallOptions[K, V](opts)
+	if err != nil {
+		return errorResults[K, V](keys, err)
+	}
+
+	cand := g.newCandidate(ctx, fn)
+	calls, toCall := g.registerX(ctx, keys, nil, nil, cand)
+
+	g.startCallX(ctx, calls, toCall, fn, funcID(fn))
+
+	return g.completeX(ctx, g.waitContextX(ctx, calls), co)
+}
+
+// startCallX handles the calls of keys on a goroutine owned by the group,
+// on behalf of a caller waiting with ctx. The keys are merged into the
+// pending batch of fn instead, if g has a merge delay. id identifies the
+// function given by the caller, see funcID.
+func (g *Group[K, V]) startCallX(ctx context.Context, calls map[K]*call[K, V], keys []K, fn func(context.Context, []K) (map[K]V, error), id uintptr) {
+	if len(keys) == 0 {
+		return
+	}
+
+	if g.MergeDelay > 0 {
+		g.merge.add(g, ctx, calls, keys, fn, id, g.MergeDelay, 0)
+		return
+	}
+
+	go g.doCallXContext(ctx, calls, keys, fn)
+}
+
+// doCallXContext is like doCallX, for a function that honors the
+// cancellation of its execution.
+func (g *Group[K, V]) doCallXContext(ctx context.Context, calls map[K]*call[K, V], keys []K, fn func(context.Context, []K) (map[K]V, error)) {
+	g.doCallXBatches(ctx, calls, keys, func(ctx context.Context, keys []K) (map[K]V, error) {
+		fnCtx, e := g.startExecution(ctx, calls, keys)
+		defer e.done()
+		return g.limit(g.bisect(fn))(fnCtx, keys)
+	}, false)
+}
+
+// doCallXBatches splits keys into batches of at most g.MaxBatchSize keys,
+// and handles the call of each batch. The first batch is handled on the
+// current goroutine, the other ones on their own goroutine. inline reports
+// whether the current goroutine is the one of the caller that started the
+// calls.
+func (g *Group[K, V]) doCallXBatches(ctx context.Context, calls map[K]*call[K, V], keys []K, fn func(context.Context, []K) (map[K]V, error), inline bool) {
+	if g.MaxBatchSize <= 0 || len(keys) <= g.MaxBatchSize {
+		g.doCallX(ctx, calls, keys, fn, inline)
+		return
+	}
+
+	batches := chunk(keys, g.MaxBatchSize)
+	for _, batch := range batches[1:] {
+		go g.doCallX(ctx, calls, batch, fn, false)
+	}
+	g.doCallX(ctx, calls, batches[0], fn, inline)
+}
+
+// registerX joins the in-flight calls of keys and creates a call for the
+// other ones, on behalf of a caller waiting with ctx. The results are also
+// sent on chans, if not nil, and on stream, if not nil. cand, if not nil, is
+// registered on the joined calls. toCall holds the keys the caller is
+// responsible for.
+func (g *Group[K, V]) registerX(ctx context.Context, keys []K, chans map[K]chan Result[V], stream *resultStream[K, V], cand *candidate[K, V]) (calls map[K]*call[K, V], toCall []K) {
+	calls = make(map[K]*call[K, V], len(keys))
+	toCall = []K{}
+	joined := []K{}
+	traceCtxs := []context.Context{}
 
 	g.mu.Lock()
 	if g.m == nil {
-		g.m = make(map[K]*call[V])
+		g.m = make(map[K]*call[K, V])
 	}
 	for _, k := range keys {
-		if c, ok := g.m[k]; ok {
+		if _, ok := calls[k]; ok {
+			continue
+		}
+
+		c, ok := g.m[k]
+		if ok {
 			c.dups++
-			calls[k] = c
+			c.join(ctx)
+			c.addCandidate(cand)
+			joined = append(joined, k)
+			traceCtxs = append(traceCtxs, g.addTraceJoin(ctx, c))
 		} else {
-			c := new(call[V])
-			c.wg.Add(1)
+			c = newCall[K, V](ctx)
 			g.m[k] = c
-			calls[k] = c
 			toCall = append(toCall, k)
 		}
+
+		if chans != nil {
+			c.chans = append(c.chans, chans[k])
+		}
+		if stream != nil {
+			c.streams = append(c.streams, stream)
+		}
+		calls[k] = c
 	}
 	g.mu.Unlock()
 
-	g.doCallX(calls, toCall, fn)
+	g.observeJoin(joined...)
+	for i, k := range joined {
+		g.traceJoin(ctx, traceCtxs[i], k)
+	}
+
+	return calls, toCall
+}
 
-	for k, c := range calls {
-		c.wg.Wait()
+// waitContextX waits for every call to complete or for ctx to be done,
+// whichever happens first.
+func (g *Group[K, V]) waitContextX(ctx context.Context, calls map[K]*call[K, V]) (results map[K]Result[V]) {
+	results = make(map[K]Result[V], len(calls))
+	left := []*call[K, V]{}
 
-		if e, ok := c.err.(*panicError); ok {
-			panic(e)
-		} else if c.err == errGoexit {
-			runtime.Goexit()
+	for k, c := range calls {
+		if !waitDone(ctx, c.done) {
+			results[k] = Result[V]{Err: ctx.Err()}
+			left = append(left, c)
+			continue
 		}
 
-		results[k] = Result[V]{NullValue[V]{c.value, !c.absent}, c.err, c.dups > 0}
+		results[k] = g.resultX(c)
+	}
+
+	if len(left) > 0 {
+		g.leave(ctx, left...)
 	}
 
 	return results
 }
 
+// resultX returns the result of the completed call c, or propagates its
+// panic or runtime.Goexit to the caller.
+func (g *Group[K, V]) resultX(c *call[K, V]) Result[V] {
+	if e, ok := c.err.(*PanicError); ok && g.PanicPolicy != PanicAsError {
+		panic(e)
+	} else if c.err == ErrGoexit && !g.Detached {
+		runtime.Goexit()
+	}
+
+	return Result[V]{NullValue[V]{c.value, !c.absent}, c.err, c.dups > 0}
+}
+
 // DoChanX is like Do but returns a channel that will receive the
 // results when they are ready.
 //
@@ -59,79 +239,111 @@ func (g *Group[K, V]) DoChanX(keys []K, fn func([]K) (map[K]V, error)) map[K]cha
 		results[k] = make(chan Result[V], 1)
 	}
 
-	calls := make(map[K]*call[V], len(keys))
-	toCall := []K{}
+	ctxFn := ignoreContext(fn)
+	calls, toCall := g.registerX(context.Background(), keys, results, nil, g.newCandidate(context.Background(), ctxFn))
 
-	g.mu.Lock()
-	if g.m == nil {
-		g.m = make(map[K]*call[V])
-	}
-	for _, k := range keys {
-		if c, ok := g.m[k]; ok {
-			c.dups++
-			c.chans = append(g.m[k].chans, results[k])
-			calls[k] = c
-		} else {
-			c := &call[V]{chans: []chan<- Result[V]{results[k]}}
-			c.wg.Add(1)
-			g.m[k] = c
-			calls[k] = c
-			toCall = append(toCall, k)
-		}
-	}
-	g.mu.Unlock()
-
-	go g.doCallX(calls, toCall, fn)
+	g.startCallX(context.Background(), calls, toCall, ctxFn, funcID(fn))
 
 	return results
 }
 
-// doCallX handles the single call for a key.
-func (g *Group[K, V]) doCallX(c map[K]*call[V], keys []K, fn func([]K) (map[K]V, error)) {
+// doCallX handles the single call for many keys, started by a caller
+// waiting with ctx. inline reports whether doCallX runs on the goroutine of
+// that caller.
+func (g *Group[K, V]) doCallX(ctx context.Context, c map[K]*call[K, V], keys []K, fn func(context.Context, []K) (map[K]V, error), inline bool) {
 	if len(keys) == 0 {
 		return
 	}
 
 	normalReturn := false
 	recovered := false
+	executed := keys
+	ctx, end := g.startTrace(ctx, c, keys)
+	start := g.observeStart(keys)
+
+	// Once closed, keys only holds the keys that were not emitted by a
+	// streaming function.
+	ctx, s := g.withStream(ctx, c, keys)
+
+	// err is the error of the whole execution.
+	var err error
 
 	// use double-defer to distinguish panic from runtime.Goexit,
 	// more details see https://golang.org/cl/134395
 	defer func() {
 		// the given function invoked runtime.Goexit
-		if !normalReturn && !recovered {
+		goexit := !normalReturn && !recovered
+		if goexit {
+			err = ErrGoexit
 			for _, key := range keys {
-				c[key].err = errGoexit
+				c[key].err = err
 			}
 		}
 
+		dups := 0
+		crash := false
+		waiting := false
+
 		g.mu.Lock()
-		defer g.mu.Unlock()
+
+		if goexit && g.GoexitPolicy == GoexitReelect {
+			if inline {
+				// The goroutine of the caller is terminating.
+				for _, key := range keys {
+					c[key].unjoin(context.Background())
+				}
+			}
+			keys = g.reelect(c, keys)
+		}
+
+		e, panicked := err.(*PanicError)
 
 		for _, key := range keys {
-			c[key].wg.Done()
 			if g.m[key] == c[key] {
 				delete(g.m, key)
 			}
+			close(c[key].done)
 
-			if e, ok := c[key].err.(*panicError); ok {
-				// In order to prevent the waiting channels from being blocked forever,
-				// needs to ensure that this panic cannot be recovered.
-				if len(c[key].chans) > 0 {
-					go panic(e)
-					select {} // Keep this goroutine around so that it will appear in the crash dump.
-				} else {
-					panic(e)
-				}
-			} else if c[key].err == errGoexit {
-				// Already in the process of goexit, no need to call again
+			if panicked && g.PanicPolicy == PanicCrash {
+				// Handled below, once every call is completed.
+				crash = crash || c[key].notified()
+				waiting = waiting || c[key].waiters > 0
+			} else if goexit && !g.Detached {
+				// Already in the process of goexit, no need to call again. Streams
+				// are closed after their last key, so they receive ErrGoexit.
+				c[key].notifyStreams(key, Result[V]{Err: err})
 			} else {
-				// Normal return
-				for _, ch := range c[key].chans {
-					ch <- Result[V]{NullValue[V]{c[key].value, !c[key].absent}, c[key].err, c[key].dups > 0}
-				}
+				// Normal return, or a panic reported to the channels as an error
+				c[key].notify(key, absentResult(Result[V]{NullValue[V]{c[key].value, !c[key].absent}, c[key].err, c[key].dups > 0}, g.AbsentPolicy, g.AbsentDefault))
 			}
 		}
+
+		for _, key := range executed {
+			dups += c[key].dups
+		}
+		// Nobody is left to re-panic.
+		crash = crash || (panicked && g.PanicPolicy == PanicCrash && !waiting)
+		g.mu.Unlock()
+
+		if panicked {
+			g.observePanic(executed, e)
+		}
+		g.observeComplete(executed, err, start, dups)
+		g.endTrace(end, err)
+
+		// Every call is completed before panicking, so that waiters of the
+		// other keys are not blocked forever.
+		if panicked && g.PanicPolicy != PanicAsError {
+			// In order to prevent the waiting channels from being blocked forever,
+			// needs to ensure that this panic cannot be recovered.
+			if crash {
+				go panic(e)
+				select {} // Keep this goroutine around so that it will appear in the crash dump.
+			} else if inline {
+				panic(e)
+			}
+			// Otherwise, waiters re-panic on their own goroutine.
+		}
 	}()
 
 	func() {
@@ -145,21 +357,36 @@ func (g *Group[K, V]) doCallX(c map[K]*call[V], keys []K, fn func([]K) (map[K]V,
 				// the time we know that, the part of the stack trace relevant to the
 				// panic has been discarded.
 				if r := recover(); r != nil {
+					err = newPanicError(r)
 					for _, key := range keys {
-						c[key].err = newPanicError(r)
+						c[key].err = err
+						c[key].absent = true
 					}
 				}
 			}
 		}()
+		defer func() {
+			keys = s.close(keys)
+		}()
 
-		values, err := fn(keys)
+		var values map[K]V
+		values, err = fn(ctx, executed)
+		keys = s.close(keys)
 		if values == nil {
 			values = make(map[K]V, len(keys))
 		}
 
+		// keys fail independently
+		keyErrs, independent := err.(keyErrors[K])
+
 		for _, key := range keys {
-			c[key].err = err
-			if v, ok := values[key]; ok {
+			v, ok := values[key]
+			if independent {
+				c[key].err = keyErrs.keyError(key, ok)
+			} else {
+				c[key].err = err
+			}
+			if ok {
 				c[key].value = v
 			} else {
 				c[key].absent = true
@@ -2,11 +2,13 @@ package singleflightx
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"os"
 	"os/exec"
 	"runtime"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -257,6 +259,49 @@ func TestGoexitDoX(t *testing.T) {
 	}
 }
 
+func TestPanicDoXContextAbandoned(t *testing.T) {
+	if os.Getenv("TEST_PANIC_DOCHAN") != "" {
+		g := new(Group[string, int])
+		release := make(chan struct{})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		results := g.DoXContext(ctx, []string{"a", "b"}, func(context.Context, []string) (map[string]int, error) {
+			<-release
+			panic("Panicking in abandoned DoXContext")
+		})
+		if results["a"].Err != context.Canceled {
+			t.Fatalf("DoXContext unexpectedly returned %v", results["a"].Err)
+		}
+		close(release)
+		time.Sleep(time.Second)
+		t.Fatalf("Abandoned panic unexpectedly dropped")
+	}
+
+	t.Parallel()
+
+	cmd := exec.Command(executable(t), "-test.run="+t.Name(), "-test.v")
+	cmd.Env = append(os.Environ(), "TEST_PANIC_DOCHAN=1")
+	out := new(bytes.Buffer)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := cmd.Wait()
+	t.Logf("%s:\n%s", strings.Join(cmd.Args, " "), out)
+	if err == nil {
+		t.Errorf("Test subprocess passed; want a crash due to panic in DoXContext")
+	}
+	if bytes.Contains(out.Bytes(), []byte("unexpectedly")) {
+		t.Errorf("Test subprocess failed with an unexpected failure mode.")
+	}
+	if !bytes.Contains(out.Bytes(), []byte("Panicking in abandoned DoXContext")) {
+		t.Errorf("Test subprocess failed, but the crash isn't caused by panicking in DoXContext")
+	}
+}
+
 func TestPanicDoChanX(t *testing.T) {
 	if os.Getenv("TEST_PANIC_DOCHAN") != "" {
 		defer func() {
@@ -344,3 +389,852 @@ func TestPanicDoXSharedByDoChanX(t *testing.T) {
 		t.Errorf("Test subprocess failed, but the crash isn't caused by panicking in DoX")
 	}
 }
+
+func TestDoXContext(t *testing.T) {
+	var g Group[string, string]
+
+	v := g.DoXContext(context.Background(), []string{"a", "b"}, func(ctx context.Context, keys []string) (map[string]string, error) {
+		assert.NoError(t, ctx.Err())
+		return map[string]string{"a": "foo"}, nil
+	})
+	assert.Len(t, v, 2)
+	assert.Equal(t, "foo", v["a"].Value.Value)
+	assert.True(t, v["a"].Value.Valid)
+	assert.False(t, v["b"].Value.Valid)
+	assert.Nil(t, v["a"].Err)
+	assert.Nil(t, v["b"].Err)
+	assert.Len(t, g.m, 0)
+}
+
+func TestDoXContextCancel(t *testing.T) {
+	var g Group[string, string]
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	// "a" is already in-flight, "b" is not.
+	chans := g.DoChanX([]string{"a"}, func(keys []string) (map[string]string, error) {
+		close(started)
+		<-unblock
+		return map[string]string{"a": "foo"}, nil
+	})
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	v := g.DoXContext(ctx, []string{"a", "b"}, func(ctx context.Context, keys []string) (map[string]string, error) {
+		assert.Equal(t, []string{"b"}, keys)
+		return map[string]string{"b": "bar"}, nil
+	})
+	assert.Len(t, v, 2)
+	assert.ErrorIs(t, v["a"].Err, context.Canceled)
+	assert.False(t, v["a"].Value.Valid)
+	assert.Equal(t, "bar", v["b"].Value.Value)
+	assert.Nil(t, v["b"].Err)
+
+	unblock <- struct{}{}
+	res := <-chans["a"]
+	assert.Equal(t, "foo", res.Value.Value)
+	assert.True(t, res.Shared)
+}
+
+func TestDoXContextAbandon(t *testing.T) {
+	var g Group[string, string]
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	v := g.DoXContext(ctx, []string{"a", "b"}, func(ctx context.Context, keys []string) (map[string]string, error) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})
+	assert.Len(t, v, 2)
+	assert.ErrorIs(t, v["a"].Err, context.Canceled)
+	assert.ErrorIs(t, v["b"].Err, context.Canceled)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatalf("fn not canceled after every caller gave up")
+	}
+
+	g.mu.Lock()
+	assert.Len(t, g.m, 0)
+	g.mu.Unlock()
+}
+
+func TestDoXContextDeadline(t *testing.T) {
+	var g Group[string, bool]
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	started := make(chan struct{})
+	joined := make(chan struct{})
+	var chans map[string]chan Result[bool]
+	go func() {
+		<-started
+		// A caller without deadline lifts the deadline of the execution.
+		chans = g.DoChanX([]string{"b"}, func(keys []string) (map[string]bool, error) {
+			panic("DoChanX unexpectedly executed callback")
+		})
+		close(joined)
+	}()
+
+	v := g.DoXContext(ctx, []string{"a", "b"}, func(ctx context.Context, keys []string) (map[string]bool, error) {
+		_, before := ctx.Deadline()
+		close(started)
+		<-joined
+		_, after := ctx.Deadline()
+		return map[string]bool{"a": before, "b": after}, nil
+	})
+	assert.True(t, v["a"].Value.Value)
+	assert.False(t, v["b"].Value.Value)
+
+	res := <-chans["b"]
+	assert.False(t, res.Value.Value)
+	assert.True(t, res.Shared)
+}
+
+func TestDetachedGoexitDoX(t *testing.T) {
+	g := Group[string, int]{Detached: true}
+
+	v := g.DoX([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+		runtime.Goexit()
+		return nil, nil
+	})
+	assert.Len(t, v, 2)
+	assert.ErrorIs(t, v["a"].Err, ErrGoexit)
+	assert.ErrorIs(t, v["b"].Err, ErrGoexit)
+
+	chans := g.DoChanX([]string{"a"}, func(keys []string) (map[string]int, error) {
+		runtime.Goexit()
+		return nil, nil
+	})
+	res := <-chans["a"]
+	assert.ErrorIs(t, res.Err, ErrGoexit)
+}
+
+func TestGoexitReelectDoX(t *testing.T) {
+	g := Group[string, int]{GoexitPolicy: GoexitReelect}
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	go func() {
+		g.DoX([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+			close(started)
+			<-unblock
+			runtime.Goexit()
+			return nil, nil
+		})
+	}()
+	<-started
+
+	var calls int32
+	chans := g.DoChanX([]string{"a"}, func(keys []string) (map[string]int, error) {
+		atomic.AddInt32(&calls, 1)
+		assert.Equal(t, []string{"a"}, keys)
+		return map[string]int{"a": 1}, nil
+	})
+	close(unblock)
+
+	res := <-chans["a"]
+	assert.Equal(t, 1, res.Value.Value)
+	assert.NoError(t, res.Err)
+	assert.True(t, res.Shared)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// "b" had no candidate: it is completed as usual.
+	for {
+		g.mu.Lock()
+		n := len(g.m)
+		g.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPanicAsErrorDoX(t *testing.T) {
+	g := Group[string, int]{PanicPolicy: PanicAsError}
+
+	v := g.DoX([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+		panic("Panicking in DoX")
+	})
+	assert.Len(t, v, 2)
+	assert.IsType(t, &PanicError{}, v["a"].Err)
+	assert.IsType(t, &PanicError{}, v["b"].Err)
+	assert.False(t, v["a"].Value.Valid)
+	assert.False(t, v["b"].Value.Valid)
+
+	chans := g.DoChanX([]string{"a"}, func(keys []string) (map[string]int, error) {
+		panic("Panicking in DoChanX")
+	})
+	res := <-chans["a"]
+	assert.IsType(t, &PanicError{}, res.Err)
+	assert.Len(t, g.m, 0)
+}
+
+func TestDoXMaxBatchSize(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[int, int]{MaxBatchSize: 2}
+
+	var mu sync.Mutex
+	batches := [][]int{}
+	fn := func(keys []int) (map[int]int, error) {
+		mu.Lock()
+		batches = append(batches, keys)
+		mu.Unlock()
+
+		results := map[int]int{}
+		for _, k := range keys {
+			results[k] = k * 2
+		}
+		return results, nil
+	}
+
+	results := g.DoX([]int{1, 2, 3, 4, 5}, fn)
+	is.Len(results, 5)
+	for k, r := range results {
+		is.Equal(k*2, r.Value.Value)
+		is.True(r.Value.Valid)
+	}
+	is.ElementsMatch([][]int{{1, 2}, {3, 4}, {5}}, batches)
+	is.Len(g.m, 0)
+
+	batches = [][]int{}
+	chans := g.DoChanX([]int{1, 2, 3}, fn)
+	for k, ch := range chans {
+		is.Equal(k*2, (<-ch).Value.Value)
+	}
+	mu.Lock()
+	is.ElementsMatch([][]int{{1, 2}, {3}}, batches)
+	mu.Unlock()
+
+	batches = [][]int{}
+	results = g.DoXContext(context.Background(), []int{1, 2}, func(ctx context.Context, keys []int) (map[int]int, error) {
+		return fn(keys)
+	})
+	is.Len(results, 2)
+	is.Equal([][]int{{1, 2}}, batches)
+}
+
+func TestDoXMaxBatchSizeErr(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[int, int]{MaxBatchSize: 1}
+	someErr := errors.New("error")
+
+	results := g.DoX([]int{1, 2}, func(keys []int) (map[int]int, error) {
+		if keys[0] == 2 {
+			return nil, someErr
+		}
+		return map[int]int{1: 1}, nil
+	})
+	is.NoError(results[1].Err)
+	is.Equal(1, results[1].Value.Value)
+	is.Equal(someErr, results[2].Err)
+	is.False(results[2].Value.Valid)
+}
+
+func TestDoXMaxConcurrency(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[int, int]{MaxBatchSize: 1, MaxConcurrency: 2}
+
+	var running, maxRunning int32
+	fn := func(keys []int) (map[int]int, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return map[int]int{keys[0]: keys[0]}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results := g.DoX([]int{i * 3, i*3 + 1, i*3 + 2}, fn)
+			is.Len(results, 3)
+		}(i)
+	}
+	wg.Wait()
+
+	is.LessOrEqual(atomic.LoadInt32(&maxRunning), int32(2))
+}
+
+func TestDoXContextMaxConcurrency(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[int, int]{MaxConcurrency: 1}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = g.DoX([]int{1}, func(keys []int) (map[int]int, error) {
+			close(started)
+			<-release
+			return map[int]int{1: 1}, nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var called int32
+	results := g.DoXContext(ctx, []int{2}, func(ctx context.Context, keys []int) (map[int]int, error) {
+		atomic.AddInt32(&called, 1)
+		return map[int]int{2: 2}, nil
+	})
+	is.Equal(context.DeadlineExceeded, results[2].Err)
+
+	close(release)
+	<-done
+
+	results = g.DoXContext(context.Background(), []int{2}, func(ctx context.Context, keys []int) (map[int]int, error) {
+		atomic.AddInt32(&called, 1)
+		return map[int]int{2: 2}, nil
+	})
+	is.Equal(2, results[2].Value.Value)
+	is.Equal(int32(1), atomic.LoadInt32(&called))
+}
+
+func TestDoXMergeDelay(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[int, int]{MergeDelay: 50 * time.Millisecond}
+
+	var mu sync.Mutex
+	batches := [][]int{}
+	fn := func(keys []int) (map[int]int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		batch := append([]int{}, keys...)
+		sort.Ints(batch)
+		batches = append(batches, batch)
+
+		results := map[int]int{}
+		for _, k := range keys {
+			results[k] = k * 2
+		}
+		return results, nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		results := g.DoX([]int{1, 2, 3}, fn)
+		is.Len(results, 3)
+		is.Equal(2, results[1].Value.Value)
+		is.Equal(6, results[3].Value.Value)
+	}()
+	go func() {
+		defer wg.Done()
+		results := g.DoX([]int{3, 4}, fn)
+		is.Len(results, 2)
+		is.Equal(6, results[3].Value.Value)
+		is.Equal(8, results[4].Value.Value)
+	}()
+	go func() {
+		defer wg.Done()
+		chans := g.DoChanX([]int{5}, fn)
+		is.Equal(10, (<-chans[5]).Value.Value)
+	}()
+	wg.Wait()
+
+	is.Equal([][]int{{1, 2, 3, 4, 5}}, batches)
+	is.Len(g.m, 0)
+}
+
+type tenantLoader struct {
+	tenant string
+}
+
+func (l tenantLoader) load(keys []string) (map[string]string, error) {
+	results := map[string]string{}
+	for _, k := range keys {
+		results[k] = l.tenant + ":" + k
+	}
+	return results, nil
+}
+
+func TestDoXMergeDelayFunctions(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[string, string]{MergeDelay: 20 * time.Millisecond}
+	var calls int32
+
+	load := func(tenant string) func([]string) (map[string]string, error) {
+		return func(keys []string) (map[string]string, error) {
+			atomic.AddInt32(&calls, 1)
+			return tenantLoader{tenant}.load(keys)
+		}
+	}
+	shared := load("shared")
+
+	var wg sync.WaitGroup
+	run := func(key string, fn func([]string) (map[string]string, error), expected string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			is.Equal(expected, g.DoX([]string{key}, fn)[key].Value.Value)
+		}()
+	}
+
+	// closures of the same function literal are not merged
+	run("a", load("tenantA"), "tenantA:a")
+	run("b", load("tenantB"), "tenantB:b")
+	// method values of different receivers are not merged
+	run("c", tenantLoader{"tenantC"}.load, "tenantC:c")
+	run("d", tenantLoader{"tenantD"}.load, "tenantD:d")
+	// the same func value is merged
+	run("e", shared, "shared:e")
+	run("f", shared, "shared:f")
+	wg.Wait()
+
+	is.Equal(int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestDoXContextMergeDelayTracer(t *testing.T) {
+	is := assert.New(t)
+
+	type callerKey struct{}
+	tracer := &recordingTracer[int]{}
+	observer := &recordingObserver[int]{}
+	g := Group[int, int]{MergeDelay: 50 * time.Millisecond, Tracer: tracer, Observer: observer}
+
+	fn := func(ctx context.Context, keys []int) (map[int]int, error) {
+		return map[int]int{1: 1, 2: 2}, nil
+	}
+
+	done := make(chan map[int]Result[int])
+	go func() {
+		done <- g.DoXContext(context.WithValue(context.Background(), callerKey{}, "first"), []int{1}, fn)
+	}()
+	is.Eventually(func() bool {
+		g.merge.mu.Lock()
+		defer g.merge.mu.Unlock()
+		return len(g.merge.batches) == 1
+	}, time.Second, time.Millisecond)
+
+	secondCtx := context.WithValue(context.Background(), callerKey{}, "second")
+	is.Equal(2, g.DoXContext(secondCtx, []int{2}, fn)[2].Value.Value)
+	is.Equal(1, (<-done)[1].Value.Value)
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	is.Len(tracer.starts, 1)
+	is.Equal("first", tracer.starts[0].Value(callerKey{}))
+	is.Len(tracer.joins, 1)
+	is.Equal(secondCtx, tracer.joins[0].ctx)
+	is.Equal(2, tracer.joins[0].key)
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	is.Equal([]int{2}, observer.joins)
+}
+
+func TestDoXResults(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+	someErr := errors.New("error")
+	batchErr := errors.New("batch error")
+
+	results := g.DoXResults([]string{"a", "b", "c", "d"}, func(keys []string) (map[string]Result[int], error) {
+		return map[string]Result[int]{
+			"a": {Value: NullValue[int]{1, true}},
+			"b": {Err: someErr},
+			"c": {},
+		}, batchErr
+	})
+	is.Len(results, 4)
+	is.NoError(results["a"].Err)
+	is.Equal(NullValue[int]{1, true}, results["a"].Value)
+	is.Equal(someErr, results["b"].Err)
+	is.False(results["b"].Value.Valid)
+	is.NoError(results["c"].Err)
+	is.False(results["c"].Value.Valid)
+	is.Equal(batchErr, results["d"].Err)
+	is.False(results["d"].Value.Valid)
+	is.Len(g.m, 0)
+
+	results = g.DoXResults([]string{"a"}, func(keys []string) (map[string]Result[int], error) {
+		return nil, nil
+	})
+	is.NoError(results["a"].Err)
+	is.False(results["a"].Value.Valid)
+}
+
+func TestDoXResultsJoiner(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+	someErr := errors.New("error")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan map[string]Result[int])
+	go func() {
+		done <- g.DoXResults([]string{"a", "b"}, func(keys []string) (map[string]Result[int], error) {
+			close(started)
+			<-release
+			return map[string]Result[int]{
+				"a": {Value: NullValue[int]{1, true}},
+				"b": {Err: someErr},
+			}, nil
+		})
+	}()
+	<-started
+
+	chans := g.DoChanX([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+		return nil, errors.New("unexpected")
+	})
+	close(release)
+
+	a, b := <-chans["a"], <-chans["b"]
+	is.NoError(a.Err)
+	is.Equal(1, a.Value.Value)
+	is.True(a.Shared)
+	is.Equal(someErr, b.Err)
+	is.True(b.Shared)
+
+	results := <-done
+	is.Equal(1, results["a"].Value.Value)
+	is.Equal(someErr, results["b"].Err)
+}
+
+func TestDoXFailBisect(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[int, int]{FailurePolicy: FailBisect, PanicPolicy: PanicAsError}
+	poisonErr := errors.New("poison")
+
+	var calls int32
+	fn := func(keys []int) (map[int]int, error) {
+		atomic.AddInt32(&calls, 1)
+
+		results := map[int]int{}
+		for _, k := range keys {
+			switch k {
+			case 3:
+				return nil, poisonErr
+			case 6:
+				panic("boom")
+			}
+			results[k] = k * 2
+		}
+		return results, nil
+	}
+
+	results := g.DoX([]int{1, 2, 3, 4, 5, 6, 7, 8}, fn)
+	is.Len(results, 8)
+	for _, k := range []int{1, 2, 4, 5, 7, 8} {
+		is.NoError(results[k].Err)
+		is.Equal(k*2, results[k].Value.Value)
+	}
+	is.Equal(poisonErr, results[3].Err)
+	is.False(results[3].Value.Valid)
+
+	var panicErr *PanicError
+	is.ErrorAs(results[6].Err, &panicErr)
+	is.Equal("boom", panicErr.Value())
+	is.False(results[6].Value.Valid)
+
+	// 1 + 2 halves + 4 quarters + 4 single keys
+	is.Equal(int32(11), atomic.LoadInt32(&calls))
+
+	// no failure, no bisection
+	atomic.StoreInt32(&calls, 0)
+	results = g.DoX([]int{1, 2}, fn)
+	is.Equal(2, results[1].Value.Value)
+	is.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestDoXFailBisectResults(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[int, int]{FailurePolicy: FailBisect}
+	someErr := errors.New("error")
+
+	var calls int32
+	results := g.DoXResults([]int{1, 2}, func(keys []int) (map[int]Result[int], error) {
+		atomic.AddInt32(&calls, 1)
+		return map[int]Result[int]{1: {Value: NullValue[int]{1, true}}, 2: {Err: someErr}}, nil
+	})
+	is.Equal(1, results[1].Value.Value)
+	is.Equal(someErr, results[2].Err)
+	is.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestDoXFailBisectResultsBatchError(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[int, int]{FailurePolicy: FailBisect}
+	someErr := errors.New("error")
+
+	var calls int32
+	results := g.DoXResults([]int{1, 2, 3, 4}, func(keys []int) (map[int]Result[int], error) {
+		atomic.AddInt32(&calls, 1)
+		results := make(map[int]Result[int], len(keys))
+		for _, k := range keys {
+			if k == 3 {
+				return nil, someErr
+			}
+			results[k] = Result[int]{Value: NullValue[int]{k, true}}
+		}
+		return results, nil
+	})
+	is.Len(results, 4)
+	for _, k := range []int{1, 2, 4} {
+		is.NoError(results[k].Err)
+		is.Equal(k, results[k].Value.Value)
+	}
+	is.Equal(someErr, results[3].Err)
+	is.Greater(atomic.LoadInt32(&calls), int32(1))
+}
+
+func TestDoChanXFailBisectPanic(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[int, int]{FailurePolicy: FailBisect, PanicPolicy: PanicRepanic}
+
+	chans := g.DoChanX([]int{1, 2}, func(keys []int) (map[int]int, error) {
+		for _, k := range keys {
+			if k == 2 {
+				panic("boom")
+			}
+		}
+		return map[int]int{1: 1}, nil
+	})
+
+	is.Equal(1, (<-chans[1]).Value.Value)
+	is.IsType(&PanicError{}, (<-chans[2]).Err)
+}
+
+func TestDoXFallback(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+	var fallbackCalls int32
+
+	fallback := WithFallback(func(key string) (int, error) {
+		atomic.AddInt32(&fallbackCalls, 1)
+		if key == "c" {
+			return 0, errors.New("not found")
+		}
+		return 42, nil
+	})
+
+	v := g.DoX([]string{"a", "b", "c"}, func(keys []string) (map[string]int, error) {
+		return map[string]int{"a": 1}, nil
+	}, fallback)
+	is.Len(v, 3)
+	is.Equal(1, v["a"].Value.Value)
+	is.True(v["b"].Value.Valid)
+	is.Equal(42, v["b"].Value.Value)
+	is.EqualError(v["c"].Err, "not found")
+	is.Equal(int32(2), atomic.LoadInt32(&fallbackCalls))
+
+	// failing keys are not absent
+	v = g.DoXContext(context.Background(), []string{"a"}, func(ctx context.Context, keys []string) (map[string]int, error) {
+		return nil, errors.New("batch failed")
+	}, fallback)
+	is.EqualError(v["a"].Err, "batch failed")
+	is.Equal(int32(2), atomic.LoadInt32(&fallbackCalls))
+
+	v = g.DoXResults([]string{"a"}, func(keys []string) (map[string]Result[int], error) {
+		return nil, nil
+	}, fallback)
+	is.Equal(42, v["a"].Value.Value)
+	is.Equal(int32(3), atomic.LoadInt32(&fallbackCalls))
+}
+
+func TestDoXFallbackDedup(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+	var fallbackCalls int32
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fallback := WithFallback(func(key string) (int, error) {
+		if atomic.AddInt32(&fallbackCalls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return 42, nil
+	})
+	fn := func(keys []string) (map[string]int, error) {
+		return nil, nil
+	}
+
+	done := make(chan map[string]Result[int])
+	go func() {
+		done <- g.DoX([]string{"a"}, fn, fallback)
+	}()
+	<-started
+
+	go func() {
+		done <- g.DoX([]string{"a"}, fn, fallback)
+	}()
+	is.Eventually(func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.m["a"] != nil && g.m["a"].dups == 1
+	}, time.Second, time.Millisecond)
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		v := <-done
+		is.Equal(42, v["a"].Value.Value)
+		is.True(v["a"].Shared)
+	}
+	is.Equal(int32(1), atomic.LoadInt32(&fallbackCalls))
+}
+
+func TestDoXFallbackInvalid(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+	fn := func(keys []string) (map[string]int, error) {
+		t.Fatal("unexpected call")
+		return nil, nil
+	}
+
+	v := g.DoX([]string{"a", "b"}, fn, WithFallback(func(key int) (int, error) {
+		return 0, nil
+	}))
+	is.Len(v, 2)
+	is.EqualError(v["a"].Err, "singleflightx: the fallback is not a func(string) (int, error)")
+	is.EqualError(v["b"].Err, "singleflightx: the fallback is not a func(string) (int, error)")
+
+	v = g.DoX([]string{"a"}, fn, WithFallback[string, int](nil))
+	is.EqualError(v["a"].Err, "singleflightx: nil fallback")
+
+	v = g.DoX([]string{"a"}, fn, nil)
+	is.EqualError(v["a"].Err, "singleflightx: nil call option")
+}
+
+func TestDoXAbsentPolicy(t *testing.T) {
+	is := assert.New(t)
+
+	fn := func(keys []string) (map[string]int, error) {
+		return map[string]int{"a": 1}, nil
+	}
+
+	g := Group[string, int]{AbsentPolicy: AbsentError}
+	v := g.DoX([]string{"a", "b"}, fn)
+	is.NoError(v["a"].Err)
+	is.Equal(1, v["a"].Value.Value)
+	is.ErrorIs(v["b"].Err, ErrNotFound)
+	is.False(v["b"].Value.Valid)
+
+	chans := g.DoChanX([]string{"a", "b"}, fn)
+	is.Equal(1, (<-chans["a"]).Value.Value)
+	is.ErrorIs((<-chans["b"]).Err, ErrNotFound)
+
+	// overridden by the call
+	v = g.DoXContext(context.Background(), []string{"a", "b"}, ignoreContext(fn), WithCallAbsentPolicy(AbsentNull))
+	is.NoError(v["b"].Err)
+	is.False(v["b"].Value.Valid)
+
+	v = g.DoX([]string{"a", "b"}, fn, WithCallAbsentDefault(-1))
+	is.NoError(v["b"].Err)
+	is.True(v["b"].Value.Valid)
+	is.Equal(-1, v["b"].Value.Value)
+
+	// applied after the fallback
+	v = g.DoXResults([]string{"b", "c"}, func(keys []string) (map[string]Result[int], error) {
+		return nil, nil
+	}, WithFallback(func(key string) (int, error) {
+		return 0, nil
+	}))
+	is.NoError(v["b"].Err)
+	is.True(v["b"].Value.Valid)
+
+	g2 := Group[string, int]{AbsentPolicy: AbsentDefault, AbsentDefault: 42}
+	v = g2.DoX([]string{"a", "b"}, fn)
+	is.Equal(1, v["a"].Value.Value)
+	is.True(v["b"].Value.Valid)
+	is.Equal(42, v["b"].Value.Value)
+
+	// errors are not absent
+	v = g2.DoX([]string{"a"}, func(keys []string) (map[string]int, error) {
+		return nil, errors.New("error")
+	})
+	is.EqualError(v["a"].Err, "error")
+	is.False(v["a"].Value.Valid)
+
+	v = g2.DoX([]string{"a"}, fn, WithCallAbsentDefault("zero"))
+	is.EqualError(v["a"].Err, "singleflightx: the absent default is not of type int")
+	v = g2.DoX([]string{"a"}, fn, WithCallAbsentPolicy(AbsentPolicy(-1)))
+	is.EqualError(v["a"].Err, "singleflightx: invalid absent policy -1")
+}
+
+func TestDoXSlice(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[string, int]{AbsentPolicy: AbsentError}
+	var calls int32
+
+	v := g.DoXSlice([]string{"c", "a", "b", "a"}, func(keys []string) (map[string]int, error) {
+		atomic.AddInt32(&calls, 1)
+		is.Equal([]string{"c", "a", "b"}, keys)
+		return map[string]int{"a": 1, "c": 3}, nil
+	})
+	is.Len(v, 4)
+	is.Equal(3, v[0].Value.Value)
+	is.Equal(1, v[1].Value.Value)
+	is.ErrorIs(v[2].Err, ErrNotFound)
+	is.Equal(1, v[3].Value.Value)
+	is.Equal(int32(1), atomic.LoadInt32(&calls))
+
+	v = g.DoXSlice([]string{"d", "e", "d"}, func(keys []string) (map[string]int, error) {
+		return map[string]int{"e": 5}, nil
+	}, WithFallback(func(key string) (int, error) {
+		return 4, nil
+	}))
+	is.Len(v, 3)
+	is.Equal(4, v[0].Value.Value)
+	is.Equal(5, v[1].Value.Value)
+	is.Equal(4, v[2].Value.Value)
+
+	v = g.DoXSlice([]string{"f"}, func(keys []string) (map[string]int, error) {
+		return nil, nil
+	}, WithCallAbsentDefault(-1))
+	is.Equal(-1, v[0].Value.Value)
+
+	v = g.DoXSlice([]string{}, func(keys []string) (map[string]int, error) {
+		t.Fatal("unexpected call")
+		return nil, nil
+	})
+	is.Empty(v)
+}
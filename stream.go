@@ -0,0 +1,247 @@
+package singleflightx
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// KeyedResult holds the result of a key, so that results of many keys can
+// be passed on a single channel.
+type KeyedResult[K comparable, V any] struct {
+	Key K
+	Result[V]
+}
+
+// DoXStream is like DoX but fn emits the result of each key as soon as it
+// is produced, instead of returning them at once. The callers of an
+// emitted key, including the ones that joined the in-flight call, are
+// woken immediately. The first result emitted for a key wins, and the
+// value of a key emitted with an error is ignored. The keys that are not
+// emitted are reported with the error returned by fn, or with a `Valid`
+// field set to false if nil, once fn returns.
+//
+// emit may be called from other goroutines, but calls made after fn
+// returned are ignored. Keys that were not passed to fn are ignored too.
+func (g *Group[K, V]) DoXStream(keys []K, fn func(keys []K, emit func(key K, v V, err error)) error, opts ...CallOption) (results map[K]Result[V]) {
+	return g.doX(keys, streamResults(fn), funcID(fn), opts)
+}
+
+// DoXStream is like DoX but fn emits the result of each key as soon as it
+// is produced. See Group.DoXStream. If the keys match different shards, fn
+// is called once per shard, concurrently.
+func (sg *ShardedGroup[K, V]) DoXStream(keys []K, fn func(keys []K, emit func(key K, v V, err error)) error, opts ...CallOption) (results map[K]Result[V]) {
+	return sg.doXContext(context.Background(), keys, streamResults(fn), funcID(fn), opts)
+}
+
+// DoXStreamChan is like DoX but returns a channel that receives the result
+// of each key once, as soon as it is ready, and is closed after the last
+// one. A key given many times is only reported once.
+//
+// The channel is buffered, so that the consumer may stop receiving at any
+// time without blocking the calls. Use DoXStreamChanContext to give up
+// waiting for the calls as well.
+func (g *Group[K, V]) DoXStreamChan(keys []K, fn func([]K) (map[K]V, error)) <-chan KeyedResult[K, V] {
+	return g.doXStreamChan(context.Background(), keys, ignoreContext(fn), funcID(fn))
+}
+
+// DoXStreamChanContext is like DoXStreamChan but gives up waiting for the
+// keys that are not resolved yet as soon as ctx is done, like DoXContext.
+// The channel still receives their result, and is closed after the last
+// one, as the calls complete or are canceled.
+func (g *Group[K, V]) DoXStreamChanContext(ctx context.Context, keys []K, fn func(context.Context, []K) (map[K]V, error)) <-chan KeyedResult[K, V] {
+	return g.doXStreamChan(ctx, keys, fn, funcID(fn))
+}
+
+// doXStreamChan handles DoXStreamChan and DoXStreamChanContext. id
+// identifies the function given by the caller, see funcID.
+func (g *Group[K, V]) doXStreamChan(ctx context.Context, keys []K, fn func(context.Context, []K) (map[K]V, error), id uintptr) <-chan KeyedResult[K, V] {
+	s := newResultStream[K, V](keys)
+	if s.left == 0 {
+		return s.ch
+	}
+
+	calls, toCall := g.registerX(ctx, keys, nil, s, g.newCandidate(ctx, fn))
+
+	g.startCallX(ctx, calls, toCall, fn, id)
+	g.leaveOnDone(ctx, calls)
+
+	return s.ch
+}
+
+// DoXStreamChan is like DoX but returns a channel that receives the result
+// of each key once, as soon as it is ready. See Group.DoXStreamChan. If the
+// keys match different shards, fn is called once per shard, concurrently.
+func (sg *ShardedGroup[K, V]) DoXStreamChan(keys []K, fn func([]K) (map[K]V, error)) <-chan KeyedResult[K, V] {
+	return sg.doXStreamChan(context.Background(), keys, ignoreContext(fn), funcID(fn))
+}
+
+// DoXStreamChanContext is like DoXStreamChan but gives up waiting for the
+// keys that are not resolved yet as soon as ctx is done. See
+// Group.DoXStreamChanContext.
+func (sg *ShardedGroup[K, V]) DoXStreamChanContext(ctx context.Context, keys []K, fn func(context.Context, []K) (map[K]V, error)) <-chan KeyedResult[K, V] {
+	return sg.doXStreamChan(ctx, keys, fn, funcID(fn))
+}
+
+// doXStreamChan handles DoXStreamChan and DoXStreamChanContext. id
+// identifies the function given by the caller, see funcID.
+func (sg *ShardedGroup[K, V]) doXStreamChan(ctx context.Context, keys []K, fn func(context.Context, []K) (map[K]V, error), id uintptr) <-chan KeyedResult[K, V] {
+	s := newResultStream[K, V](keys)
+	if s.left == 0 {
+		return s.ch
+	}
+
+	keysByShard := partitionBy(keys, func(key K) uint {
+		return sg.hasher.computeHash(key, sg.count)
+	})
+
+	for i, keys := range keysByShard {
+		calls, toCall := sg.shards[i].registerX(ctx, keys, nil, s, sg.shards[i].newCandidate(ctx, fn))
+		sg.shards[i].startCallX(ctx, calls, toCall, fn, id)
+		sg.shards[i].leaveOnDone(ctx, calls)
+	}
+
+	return s.ch
+}
+
+// leaveOnDone gives up waiting for the calls that are not completed once
+// ctx is done, on behalf of a caller that does not wait for them on its own
+// goroutine. It returns immediately if ctx is never done.
+func (g *Group[K, V]) leaveOnDone(ctx context.Context, calls map[K]*call[K, V]) {
+	if ctx.Done() == nil {
+		return
+	}
+
+	go func() {
+		left := []*call[K, V]{}
+		for _, c := range calls {
+			if !waitDone(ctx, c.done) {
+				left = append(left, c)
+			}
+		}
+
+		if len(left) > 0 {
+			g.leave(ctx, left...)
+		}
+	}()
+}
+
+// resultStream sends the results of many keys on a single channel, and
+// closes it after the last one.
+type resultStream[K comparable, V any] struct {
+	ch   chan KeyedResult[K, V]
+	left int32 // number of keys without result
+
+	// pending holds the keys not received yet. It is only accessed by the
+	// consumer of ch.
+	pending map[K]struct{}
+}
+
+func newResultStream[K comparable, V any](keys []K) *resultStream[K, V] {
+	unique := make(map[K]struct{}, len(keys))
+	for _, k := range keys {
+		unique[k] = struct{}{}
+	}
+
+	s := &resultStream[K, V]{ch: make(chan KeyedResult[K, V], len(unique)), left: int32(len(unique)), pending: unique}
+	if s.left == 0 {
+		close(s.ch)
+	}
+	return s
+}
+
+// send sends the result r of key. It must be called once per key.
+func (s *resultStream[K, V]) send(key K, r Result[V]) {
+	s.ch <- KeyedResult[K, V]{key, r}
+	if atomic.AddInt32(&s.left, -1) == 0 {
+		close(s.ch)
+	}
+}
+
+// streamResults adapts a streaming function to the function of DoX. It
+// relies on the stream set by doCallX on the context.
+func streamResults[K comparable, V any](fn func([]K, func(K, V, error)) error) func(context.Context, []K) (map[K]V, error) {
+	return func(ctx context.Context, keys []K) (map[K]V, error) {
+		s := ctx.Value(streamKey{}).(*stream[K, V])
+		return nil, fn(keys, s.emit)
+	}
+}
+
+type streamKey struct{}
+
+// stream completes the calls of an execution as soon as their results are
+// emitted, until closed.
+type stream[K comparable, V any] struct {
+	g     *Group[K, V]
+	calls map[K]*call[K, V]
+	keys  []K // keys of the execution
+
+	mu       sync.Mutex // protects following fields
+	closed   bool
+	batch    map[K]struct{} // lazily initialized from keys
+	resolved int
+}
+
+// withStream returns a copy of ctx carrying a stream for the calls of
+// keys.
+func (g *Group[K, V]) withStream(ctx context.Context, calls map[K]*call[K, V], keys []K) (context.Context, *stream[K, V]) {
+	s := &stream[K, V]{g: g, calls: calls, keys: keys}
+	return context.WithValue(ctx, streamKey{}, s), s
+}
+
+// emit completes the call of key, if part of the execution.
+func (s *stream[K, V]) emit(key K, v V, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	if s.batch == nil {
+		s.batch = make(map[K]struct{}, len(s.keys))
+		for _, k := range s.keys {
+			s.batch[k] = struct{}{}
+		}
+	}
+	if _, ok := s.batch[key]; !ok {
+		return
+	}
+
+	g, c := s.g, s.calls[key]
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c.resolved {
+		return
+	}
+	c.resolved = true
+	s.resolved++
+
+	c.value, c.err, c.absent = v, err, err != nil
+	if g.m[key] == c {
+		delete(g.m, key)
+	}
+	close(c.done)
+
+	c.notify(key, absentResult(Result[V]{NullValue[V]{c.value, !c.absent}, c.err, c.dups > 0}, g.AbsentPolicy, g.AbsentDefault))
+}
+
+// close stops s, and returns the keys that were not emitted.
+func (s *stream[K, V]) close(keys []K) []K {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.resolved == 0 {
+		return keys
+	}
+
+	pending := make([]K, 0, len(keys))
+	for _, k := range keys {
+		if !s.calls[k].resolved {
+			pending = append(pending, k)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,323 @@
+package singleflightx
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoXStream(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+	someErr := errors.New("error")
+
+	started := make(chan struct{})
+	emit := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan map[string]Result[int])
+	go func() {
+		done <- g.DoXStream([]string{"a", "b", "c", "d"}, func(keys []string, e func(string, int, error)) error {
+			close(started)
+			<-emit
+			e("a", 1, nil)
+			e("b", 2, someErr)
+			e("z", 26, nil) // not requested
+			<-release
+			e("a", 42, nil) // already emitted
+			return nil
+		})
+	}()
+	<-started
+
+	chans := g.DoChanX([]string{"a", "b", "c"}, func(keys []string) (map[string]int, error) {
+		return nil, errors.New("unexpected")
+	})
+	close(emit)
+
+	// the emitted keys are resolved before the end of the batch
+	a, b := <-chans["a"], <-chans["b"]
+	is.Equal(1, a.Value.Value)
+	is.True(a.Shared)
+	is.Equal(someErr, b.Err)
+	is.False(b.Value.Valid)
+	select {
+	case <-chans["c"]:
+		t.Fatal("unexpected result")
+	default:
+	}
+
+	// emitted keys are not in-flight anymore
+	v := g.DoX([]string{"a", "z"}, func(keys []string) (map[string]int, error) {
+		return map[string]int{"a": 10}, nil
+	})
+	is.Equal(10, v["a"].Value.Value)
+	is.False(v["z"].Value.Valid)
+
+	close(release)
+	c := <-chans["c"]
+	is.NoError(c.Err)
+	is.False(c.Value.Valid)
+	is.True(c.Shared)
+
+	results := <-done
+	is.Len(results, 4)
+	is.Equal(1, results["a"].Value.Value)
+	is.Equal(someErr, results["b"].Err)
+	is.False(results["c"].Value.Valid)
+	is.False(results["d"].Value.Valid)
+
+	// keys that are not emitted get the error of the batch
+	results = g.DoXStream([]string{"a", "b"}, func(keys []string, emit func(string, int, error)) error {
+		emit("a", 1, nil)
+		return someErr
+	})
+	is.Equal(1, results["a"].Value.Value)
+	is.NoError(results["a"].Err)
+	is.Equal(someErr, results["b"].Err)
+}
+
+func TestDoXStreamLateEmit(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+	var emit func(string, int, error)
+
+	results := g.DoXStream([]string{"a"}, func(keys []string, e func(string, int, error)) error {
+		emit = e
+		return nil
+	}, WithCallAbsentPolicy(AbsentError))
+	is.ErrorIs(results["a"].Err, ErrNotFound)
+
+	// ignored once the function returned
+	emit("a", 1, nil)
+
+	results = g.DoXStream([]string{"a"}, func(keys []string, emit func(string, int, error)) error {
+		emit("a", 2, nil)
+		return nil
+	})
+	is.Equal(2, results["a"].Value.Value)
+}
+
+func TestDoXStreamPanic(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[string, int]{PanicPolicy: PanicAsError, MaxBatchSize: 1}
+
+	results := g.DoXStream([]string{"a", "b"}, func(keys []string, emit func(string, int, error)) error {
+		emit(keys[0], 1, nil)
+		if keys[0] == "b" {
+			panic("boom")
+		}
+		return nil
+	})
+	is.Equal(1, results["a"].Value.Value)
+	is.Equal(1, results["b"].Value.Value)
+	is.NoError(results["b"].Err)
+
+	results = g.DoXStream([]string{"a"}, func(keys []string, emit func(string, int, error)) error {
+		panic("boom")
+	})
+	var panicErr *PanicError
+	is.ErrorAs(results["a"].Err, &panicErr)
+}
+
+func TestShardedGroupDoXStream(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t)
+
+	results := sg.DoXStream([]int{1, 2, 3}, func(keys []int, emit func(int, int, error)) error {
+		is.Len(keys, 1)
+		emit(keys[0], keys[0]*2, nil)
+		return nil
+	})
+	is.Len(results, 3)
+	is.Equal(2, results[1].Value.Value)
+	is.Equal(4, results[2].Value.Value)
+	is.Equal(6, results[3].Value.Value)
+}
+
+func TestDoXStreamChan(t *testing.T) {
+	is := assert.New(t)
+
+	g := Group[string, int]{MaxBatchSize: 1}
+
+	release := make(chan struct{})
+	ch := g.DoXStreamChan([]string{"a", "b", "a", "c"}, func(keys []string) (map[string]int, error) {
+		switch keys[0] {
+		case "b":
+			<-release
+			return nil, errors.New("error")
+		case "c":
+			return nil, nil
+		}
+		return map[string]int{keys[0]: 1}, nil
+	})
+
+	results := map[string]Result[int]{}
+	for i := 0; i < 2; i++ {
+		r := <-ch
+		results[r.Key] = r.Result
+	}
+	is.Equal(1, results["a"].Value.Value)
+	is.False(results["c"].Value.Valid)
+	is.NoError(results["c"].Err)
+
+	close(release)
+	r, ok := <-ch
+	is.True(ok)
+	is.Equal("b", r.Key)
+	is.EqualError(r.Err, "error")
+
+	_, ok = <-ch
+	is.False(ok)
+
+	_, ok = <-g.DoXStreamChan(nil, func(keys []string) (map[string]int, error) {
+		t.Fatal("unexpected call")
+		return nil, nil
+	})
+	is.False(ok)
+}
+
+func TestDoXStreamChanJoin(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go g.Do("a", func() (int, error) {
+		close(started)
+		<-release
+		return 1, nil
+	})
+	<-started
+
+	ch := g.DoXStreamChan([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+		is.Equal([]string{"b"}, keys)
+		return map[string]int{"b": 2}, nil
+	})
+
+	r := <-ch
+	is.Equal("b", r.Key)
+	is.Equal(2, r.Value.Value)
+
+	close(release)
+	r = <-ch
+	is.Equal("a", r.Key)
+	is.Equal(1, r.Value.Value)
+	is.True(r.Shared)
+
+	_, ok := <-ch
+	is.False(ok)
+}
+
+func TestDoXStreamChanGoexit(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go g.Do("a", func() (int, error) {
+		close(started)
+		<-release
+		runtime.Goexit()
+		return 0, nil
+	})
+	<-started
+
+	ch := g.DoXStreamChan([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+		runtime.Goexit()
+		return nil, nil
+	})
+
+	r := <-ch
+	is.Equal("b", r.Key)
+	is.ErrorIs(r.Err, ErrGoexit)
+
+	close(release)
+	r = <-ch
+	is.Equal("a", r.Key)
+	is.ErrorIs(r.Err, ErrGoexit)
+
+	_, ok := <-ch
+	is.False(ok)
+}
+
+func TestDoXStreamChanContext(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+
+	type callerKey struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), callerKey{}, "caller"))
+	started := make(chan struct{})
+	ch := g.DoXStreamChanContext(ctx, []string{"a", "b"}, func(ctx context.Context, keys []string) (map[string]int, error) {
+		is.Equal("caller", ctx.Value(callerKey{}))
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+
+	// the consumer gives up, so that nobody waits for the execution anymore
+	cancel()
+
+	results := map[string]Result[int]{}
+	for r := range ch {
+		results[r.Key] = r.Result
+	}
+	is.Len(results, 2)
+	is.ErrorIs(results["a"].Err, context.Canceled)
+	is.ErrorIs(results["b"].Err, context.Canceled)
+
+	g.mu.Lock()
+	is.Empty(g.m)
+	g.mu.Unlock()
+}
+
+func TestShardedGroupDoXStreamChan(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t)
+
+	results := map[int]int{}
+	for r := range sg.DoXStreamChan([]int{1, 2, 3, 5}, func(keys []int) (map[int]int, error) {
+		values := map[int]int{}
+		for _, k := range keys {
+			values[k] = k * 2
+		}
+		return values, nil
+	}) {
+		is.NoError(r.Err)
+		results[r.Key] = r.Value.Value
+	}
+	is.Equal(map[int]int{1: 2, 2: 4, 3: 6, 5: 10}, results)
+}
+
+func TestShardedGroupDoXStreamChanContext(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var started sync.WaitGroup
+	started.Add(2)
+	ch := sg.DoXStreamChanContext(ctx, []int{1, 2}, func(ctx context.Context, keys []int) (map[int]int, error) {
+		started.Done()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	started.Wait()
+	cancel()
+
+	n := 0
+	for r := range ch {
+		is.ErrorIs(r.Err, context.Canceled)
+		n++
+	}
+	is.Equal(2, n)
+}
@@ -0,0 +1,99 @@
+package singleflightx
+
+import "context"
+
+// Tracer is notified of every execution of the given functions, with the
+// context of the caller that started it, and of the callers joining it,
+// with their own context. It allows a tracing adapter to link the span of
+// every waiting caller to the span of the execution that served it. Its
+// methods must be safe for concurrent use.
+type Tracer[K comparable] interface {
+	// StartExecution is called before the given function is executed for
+	// keys, on behalf of a caller waiting with ctx. ctx does not derive from
+	// the context of that caller if the group is detached. The returned
+	// context is the one of the execution: the context passed to the
+	// function of DoContext and DoXContext carries its values. end is called
+	// with the error of the execution once it is over.
+	StartExecution(ctx context.Context, keys []K) (execCtx context.Context, end func(err error))
+	// Join is called when a caller waiting with ctx joins the execution in
+	// charge of key, whose context is execCtx. It may be called from the
+	// goroutine of the execution, if the caller joined before the execution
+	// started. The first caller of a detached group joins the execution as
+	// well.
+	Join(ctx context.Context, execCtx context.Context, key K)
+}
+
+// startTrace returns the context of the execution in charge of the calls of
+// keys, started by a caller waiting with ctx, and the function ending its
+// trace, if any. The context does not derive from ctx if g is detached.
+func (g *Group[K, V]) startTrace(ctx context.Context, calls map[K]*call[K, V], keys []K) (context.Context, func(error)) {
+	callerCtx := ctx
+	if g.Detached {
+		ctx = context.Background()
+	}
+
+	if g.Tracer == nil {
+		return ctx, nil
+	}
+
+	execCtx, end := g.Tracer.StartExecution(ctx, keys)
+
+	type join struct {
+		ctx context.Context
+		key K
+	}
+	joins := []join{}
+
+	g.mu.Lock()
+	for _, k := range keys {
+		c := calls[k]
+		c.traceCtx = execCtx
+		for _, joinCtx := range c.traceJoins {
+			joins = append(joins, join{joinCtx, k})
+		}
+		c.traceJoins = nil
+	}
+	g.mu.Unlock()
+
+	if g.Detached {
+		for _, k := range keys {
+			joins = append(joins, join{callerCtx, k})
+		}
+	}
+
+	for _, j := range joins {
+		g.Tracer.Join(j.ctx, execCtx, j.key)
+	}
+
+	return execCtx, end
+}
+
+// endTrace ends the trace of an execution, if any.
+func (g *Group[K, V]) endTrace(end func(error), err error) {
+	if end != nil {
+		end(err)
+	}
+}
+
+// addTraceJoin registers a caller waiting with ctx that joined c. It returns
+// the context of the execution in charge of c, or nil if it has not started
+// yet, in which case the tracer is notified once it starts. It must be
+// called with the singleflight mutex held.
+func (g *Group[K, V]) addTraceJoin(ctx context.Context, c *call[K, V]) context.Context {
+	if g.Tracer == nil {
+		return nil
+	}
+
+	if c.traceCtx == nil {
+		c.traceJoins = append(c.traceJoins, ctx)
+	}
+	return c.traceCtx
+}
+
+// traceJoin notifies the tracer that a caller waiting with ctx joined the
+// execution of key, whose context is execCtx, if not nil.
+func (g *Group[K, V]) traceJoin(ctx context.Context, execCtx context.Context, key K) {
+	if execCtx != nil {
+		g.Tracer.Join(ctx, execCtx, key)
+	}
+}
@@ -0,0 +1,171 @@
+package singleflightx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tracerCtxKey struct{}
+
+type tracedJoin[K comparable] struct {
+	ctx     context.Context
+	execCtx context.Context
+	key     K
+}
+
+type recordingTracer[K comparable] struct {
+	mu     sync.Mutex
+	starts []context.Context
+	joins  []tracedJoin[K]
+	ends   []error
+}
+
+func (tr *recordingTracer[K]) StartExecution(ctx context.Context, keys []K) (context.Context, func(error)) {
+	execCtx := context.WithValue(ctx, tracerCtxKey{}, keys)
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.starts = append(tr.starts, ctx)
+
+	return execCtx, func(err error) {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		tr.ends = append(tr.ends, err)
+	}
+}
+
+func (tr *recordingTracer[K]) Join(ctx context.Context, execCtx context.Context, key K) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.joins = append(tr.joins, tracedJoin[K]{ctx, execCtx, key})
+}
+
+func (tr *recordingTracer[K]) ended(n int) func() bool {
+	return func() bool {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		return len(tr.ends) == n
+	}
+}
+
+func TestTracerDoContext(t *testing.T) {
+	is := assert.New(t)
+
+	tracer := &recordingTracer[string]{}
+	g := Group[string, int]{Tracer: tracer}
+
+	type callerKey struct{}
+	leaderCtx := context.WithValue(context.Background(), callerKey{}, "leader")
+	joinerCtx := context.WithValue(context.Background(), callerKey{}, "joiner")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	someErr := errors.New("error")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err, _ := g.DoContext(leaderCtx, "key", func(ctx context.Context) (int, error) {
+			is.Equal([]string{"key"}, ctx.Value(tracerCtxKey{}))
+			is.Equal("leader", ctx.Value(callerKey{}))
+			close(started)
+			<-release
+			return 0, someErr
+		})
+		is.Equal(someErr, err)
+	}()
+	<-started
+
+	joinerDone := make(chan error, 1)
+	go func() {
+		_, err, shared := g.DoContext(joinerCtx, "key", func(ctx context.Context) (int, error) {
+			return 0, nil
+		})
+		is.True(shared)
+		joinerDone <- err
+	}()
+	for dups := 0; dups == 0; {
+		time.Sleep(time.Millisecond)
+		g.mu.Lock()
+		dups = g.m["key"].dups
+		g.mu.Unlock()
+	}
+
+	close(release)
+	<-done
+	is.Equal(someErr, <-joinerDone)
+	is.Eventually(tracer.ended(1), time.Second, time.Millisecond)
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	is.Len(tracer.starts, 1)
+	is.Equal("leader", tracer.starts[0].Value(callerKey{}))
+	is.Len(tracer.joins, 1)
+	is.Equal("joiner", tracer.joins[0].ctx.Value(callerKey{}))
+	is.Equal([]string{"key"}, tracer.joins[0].execCtx.Value(tracerCtxKey{}))
+	is.Equal("key", tracer.joins[0].key)
+	is.Equal([]error{someErr}, tracer.ends)
+}
+
+func TestTracerDoXJoinBeforeStart(t *testing.T) {
+	is := assert.New(t)
+
+	tracer := &recordingTracer[string]{}
+	g := Group[string, int]{Tracer: tracer}
+
+	c := newCall[string, int](context.Background())
+	g.m = map[string]*call[string, int]{"a": c}
+
+	type callerKey struct{}
+	joinerCtx := context.WithValue(context.Background(), callerKey{}, "joiner")
+
+	calls, toCall := g.registerX(joinerCtx, []string{"a", "b"}, nil, nil, nil)
+	is.Equal([]string{"b"}, toCall)
+	is.Empty(tracer.joins)
+
+	g.doCallX(context.Background(), calls, []string{"a"}, func(ctx context.Context, keys []string) (map[string]int, error) {
+		return map[string]int{"a": 1}, nil
+	}, false)
+	g.doCallX(context.Background(), calls, toCall, func(ctx context.Context, keys []string) (map[string]int, error) {
+		return map[string]int{"b": 2}, nil
+	}, false)
+
+	results := g.waitContextX(joinerCtx, calls)
+	is.Equal(1, results["a"].Value.Value)
+	is.Equal(2, results["b"].Value.Value)
+
+	is.Len(tracer.starts, 2)
+	is.Len(tracer.joins, 1)
+	is.Equal("joiner", tracer.joins[0].ctx.Value(callerKey{}))
+	is.Equal([]string{"a"}, tracer.joins[0].execCtx.Value(tracerCtxKey{}))
+	is.Equal("a", tracer.joins[0].key)
+	is.Equal([]error{nil, nil}, tracer.ends)
+}
+
+func TestTracerDetached(t *testing.T) {
+	is := assert.New(t)
+
+	tracer := &recordingTracer[string]{}
+	g := Group[string, int]{Tracer: tracer, Detached: true}
+
+	type callerKey struct{}
+	ctx := context.WithValue(context.Background(), callerKey{}, "caller")
+
+	results := g.DoXContext(ctx, []string{"a"}, func(ctx context.Context, keys []string) (map[string]int, error) {
+		is.Nil(ctx.Value(callerKey{}))
+		is.Equal([]string{"a"}, ctx.Value(tracerCtxKey{}))
+		return map[string]int{"a": 1}, nil
+	})
+	is.Equal(1, results["a"].Value.Value)
+	is.Eventually(tracer.ended(1), time.Second, time.Millisecond)
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	is.Nil(tracer.starts[0].Value(callerKey{}))
+	is.Len(tracer.joins, 1)
+	is.Equal("caller", tracer.joins[0].ctx.Value(callerKey{}))
+}
@@ -16,3 +16,19 @@ func partitionBy[K comparable](collection []K, iteratee func(item K) uint) map[u
 
 	return result
 }
+
+func chunk[K any](collection []K, size int) [][]K {
+	chunksNum := (len(collection) + size - 1) / size
+	result := make([][]K, 0, chunksNum)
+
+	for i := 0; i < len(collection); i += size {
+		end := i + size
+		if end > len(collection) {
+			end = len(collection)
+		}
+
+		result = append(result, collection[i:end])
+	}
+
+	return result
+}
@@ -0,0 +1,52 @@
+package singleflightx
+
+import "context"
+
+// Wait joins the in-flight call of key, without ever starting one, and
+// returns its result once ready. It reports false if no call of key is in
+// flight. Like DoContext, it gives up waiting as soon as ctx is done, in
+// which case ctx.Err() is reported.
+func (g *Group[K, V]) Wait(ctx context.Context, key K) (Result[V], bool) {
+	g.mu.Lock()
+	c, ok := g.m[key]
+	if !ok {
+		g.mu.Unlock()
+		return Result[V]{}, false
+	}
+	c.dups++
+	c.join(ctx)
+	traceCtx := g.addTraceJoin(ctx, c)
+	g.mu.Unlock()
+
+	g.observeJoin(key)
+	g.traceJoin(ctx, traceCtx, key)
+
+	if !waitDone(ctx, c.done) {
+		g.leave(ctx, c)
+		return Result[V]{Err: ctx.Err()}, true
+	}
+
+	return absentResult(g.resultX(c), g.AbsentPolicy, g.AbsentDefault), true
+}
+
+// InFlight reports whether a call of key is in flight.
+func (g *Group[K, V]) InFlight(key K) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, ok := g.m[key]
+	return ok
+}
+
+// Wait joins the in-flight call of key, without ever starting one. See
+// Group.Wait.
+func (sg *ShardedGroup[K, V]) Wait(ctx context.Context, key K) (Result[V], bool) {
+	i := sg.hasher.computeHash(key, sg.count)
+	return sg.shards[i].Wait(ctx, key)
+}
+
+// InFlight reports whether a call of key is in flight.
+func (sg *ShardedGroup[K, V]) InFlight(key K) bool {
+	i := sg.hasher.computeHash(key, sg.count)
+	return sg.shards[i].InFlight(key)
+}
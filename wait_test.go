@@ -0,0 +1,120 @@
+package singleflightx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWait(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+
+	_, ok := g.Wait(context.Background(), "key")
+	is.False(ok)
+	is.False(g.InFlight("key"))
+
+	release := make(chan struct{})
+	f := g.Go("key", func() (int, error) {
+		<-release
+		return 42, nil
+	})
+	is.True(g.InFlight("key"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r, ok := g.Wait(ctx, "key")
+	is.True(ok)
+	is.ErrorIs(r.Err, context.DeadlineExceeded)
+
+	// the call is still in flight for the other callers
+	is.True(g.InFlight("key"))
+
+	done := make(chan Result[int])
+	go func() {
+		r, _ := g.Wait(context.Background(), "key")
+		done <- r
+	}()
+	is.Eventually(func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.m["key"].dups == 2
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	r = <-done
+	is.NoError(r.Err)
+	is.Equal(42, r.Value.Value)
+	is.True(r.Shared)
+	is.Equal(42, f.Await(context.Background()).Value.Value)
+	is.False(g.InFlight("key"))
+}
+
+func TestWaitAbandoned(t *testing.T) {
+	is := assert.New(t)
+
+	var g Group[string, int]
+
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go g.DoContext(ctx, "key", func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+
+	waitCtx, waitCancel := context.WithCancel(context.Background())
+	done := make(chan Result[int])
+	go func() {
+		r, _ := g.Wait(waitCtx, "key")
+		done <- r
+	}()
+	is.Eventually(func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.m["key"].dups == 1
+	}, time.Second, time.Millisecond)
+
+	// still waited for
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	is.True(g.InFlight("key"))
+
+	// abandoned once every caller gave up waiting
+	waitCancel()
+	is.ErrorIs((<-done).Err, context.Canceled)
+	is.Eventually(func() bool {
+		return !g.InFlight("key")
+	}, time.Second, time.Millisecond)
+}
+
+func TestShardedGroupWait(t *testing.T) {
+	is := assert.New(t)
+	sg := newTestShardedGroup(t)
+
+	_, ok := sg.Wait(context.Background(), 1)
+	is.False(ok)
+
+	release := make(chan struct{})
+	futures := sg.GoX([]int{1, 2}, func(keys []int) (map[int]int, error) {
+		<-release
+		return map[int]int{keys[0]: keys[0] * 2}, nil
+	})
+	is.True(sg.InFlight(1))
+	is.True(sg.InFlight(2))
+	is.False(sg.InFlight(3))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	r, ok := sg.Wait(context.Background(), 2)
+	is.True(ok)
+	is.Equal(4, r.Value.Value)
+	is.True(r.Shared)
+	is.Equal(2, futures[1].Await(context.Background()).Value.Value)
+}